@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/logrusutil"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/release"
+	"github.com/openshift/ci-tools/pkg/util"
+)
+
+type options struct {
+	imageStream string
+	tags        flagutil.Strings
+	keepLast    int
+	maxAge      time.Duration
+	dry         bool
+}
+
+func opts() *options {
+	opts := &options{}
+	flag.StringVar(&opts.imageStream, "imagestream", "", "The promoted imagestream to prune aliases from, as namespace/name")
+	flag.Var(&opts.tags, "tag", "A tag whose aliases are pruned. Can be passed multiple times.")
+	flag.IntVar(&opts.keepLast, "keep-last", 0, "The number of newest aliases of every tag to keep")
+	flag.DurationVar(&opts.maxAge, "max-age", 0, "The age after which aliases are pruned")
+	flag.BoolVar(&opts.dry, "dry-run", true, "Enable dry-run")
+	flag.Parse()
+	return opts
+}
+
+func (o *options) validate() error {
+	if _, err := parseImageStream(o.imageStream); err != nil {
+		return err
+	}
+	if len(o.tags.Strings()) == 0 {
+		return fmt.Errorf("at least one --tag must be set")
+	}
+	if o.keepLast < 0 || o.maxAge < 0 {
+		return fmt.Errorf("--keep-last and --max-age must not be negative")
+	}
+	if o.keepLast == 0 && o.maxAge == 0 {
+		return fmt.Errorf("at least one of --keep-last and --max-age must be set")
+	}
+	return nil
+}
+
+func (o *options) pruning() api.PruningConfiguration {
+	pruning := api.PruningConfiguration{KeepLast: o.keepLast}
+	if o.maxAge != 0 {
+		pruning.MaxAge = &prowv1.Duration{Duration: o.maxAge}
+	}
+	return pruning
+}
+
+func parseImageStream(raw string) (ctrlruntimeclient.ObjectKey, error) {
+	parts := strings.Split(raw, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ctrlruntimeclient.ObjectKey{}, fmt.Errorf("--imagestream must be in the namespace/name format, got %q", raw)
+	}
+	return ctrlruntimeclient.ObjectKey{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// run prunes the aliases of the tags in the imagestream
+func run(ctx context.Context, client ctrlruntimeclient.Client, o *options, now time.Time) error {
+	key, err := parseImageStream(o.imageStream)
+	if err != nil {
+		return err
+	}
+	pruned, err := release.PruneAliases(ctx, client, key, sets.NewString(o.tags.Strings()...), o.pruning(), now)
+	for _, tag := range pruned {
+		logrus.Infof("Pruned %s/%s:%s", key.Namespace, key.Name, tag)
+	}
+	return err
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := opts()
+	if err := o.validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid parameters")
+	}
+
+	if err := imagev1.AddToScheme(scheme.Scheme); err != nil {
+		logrus.WithError(err).Fatal("Failed to add imagev1 to scheme")
+	}
+	config, err := util.LoadClusterConfig()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load cluster config")
+	}
+	client, err := ctrlruntimeclient.New(config, ctrlruntimeclient.Options{})
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to construct client")
+	}
+	if o.dry {
+		client = ctrlruntimeclient.NewDryRunClient(client)
+	}
+
+	if err := run(context.Background(), client, o, time.Now()); err != nil {
+		logrus.WithError(err).Fatal("Failed to prune aliases")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/test-infra/prow/flagutil"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/openshift/api/image/v1"
+)
+
+func init() {
+	if err := imagev1.AddToScheme(scheme.Scheme); err != nil {
+		panic(fmt.Sprintf("failed to add imagev1 to scheme: %v", err))
+	}
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	aliases := []struct {
+		tag     string
+		created time.Time
+	}{
+		{tag: "cli-20200101-000000", created: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{tag: "cli-20200108-000000", created: time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{tag: "cli-20200109-000000", created: time.Date(2020, 1, 9, 0, 0, 0, 0, time.UTC)},
+		{tag: "tests-20200101-000000", created: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	objects := func() []ctrlruntimeclient.Object {
+		stream := &imagev1.ImageStream{ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8"}}
+		objects := []ctrlruntimeclient.Object{stream}
+		for _, alias := range aliases {
+			stream.Status.Tags = append(stream.Status.Tags, imagev1.NamedTagEventList{
+				Tag:   alias.tag,
+				Items: []imagev1.TagEvent{{Image: "sha256:cli", Created: meta.NewTime(alias.created)}},
+			})
+			objects = append(objects, &imagev1.ImageStreamTag{ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8:" + alias.tag}})
+		}
+		return objects
+	}
+	var testCases = []struct {
+		name          string
+		options       options
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name:     "only the newest aliases of the tag are kept",
+			options:  options{imageStream: "ocp/4.8", tags: flagutil.NewStrings("cli"), keepLast: 1},
+			expected: []string{"4.8:cli-20200109-000000", "4.8:tests-20200101-000000"},
+		},
+		{
+			name:     "aliases older than the maximum age are pruned",
+			options:  options{imageStream: "ocp/4.8", tags: flagutil.NewStrings("cli"), maxAge: 72 * time.Hour},
+			expected: []string{"4.8:cli-20200108-000000", "4.8:cli-20200109-000000", "4.8:tests-20200101-000000"},
+		},
+		{
+			name:     "aliases of every tag passed are pruned",
+			options:  options{imageStream: "ocp/4.8", tags: flagutil.NewStrings("cli", "tests"), maxAge: 72 * time.Hour},
+			expected: []string{"4.8:cli-20200108-000000", "4.8:cli-20200109-000000"},
+		},
+		{
+			name:          "imagestream must be namespaced",
+			options:       options{imageStream: "4.8", tags: flagutil.NewStrings("cli"), keepLast: 1},
+			expected:      []string{"4.8:cli-20200101-000000", "4.8:cli-20200108-000000", "4.8:cli-20200109-000000", "4.8:tests-20200101-000000"},
+			expectedError: true,
+		},
+		{
+			name:          "missing imagestream fails",
+			options:       options{imageStream: "ocp/4.9", tags: flagutil.NewStrings("cli"), keepLast: 1},
+			expected:      []string{"4.8:cli-20200101-000000", "4.8:cli-20200108-000000", "4.8:cli-20200109-000000", "4.8:tests-20200101-000000"},
+			expectedError: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewClientBuilder().WithObjects(objects()...).Build()
+			err := run(context.Background(), client, &testCase.options, now)
+			if (err != nil) != testCase.expectedError {
+				t.Fatalf("expected error: %v, got: %v", testCase.expectedError, err)
+			}
+			istags := &imagev1.ImageStreamTagList{}
+			if err := client.List(context.Background(), istags, ctrlruntimeclient.InNamespace("ocp")); err != nil {
+				t.Fatalf("could not list imagestreamtags: %v", err)
+			}
+			var actual []string
+			for _, istag := range istags.Items {
+				actual = append(actual, istag.Name)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("got incorrect remaining aliases: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/test-infra/prow/logrusutil"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps/release"
+	"github.com/openshift/ci-tools/pkg/util"
+)
+
+type options struct {
+	tag    string
+	digest string
+	reason string
+	clear  bool
+	dry    bool
+}
+
+func opts() *options {
+	opts := &options{}
+	flag.StringVar(&opts.tag, "tag", "", "The promoted tag to revert, as namespace/name:tag")
+	flag.StringVar(&opts.digest, "digest", "", "The digest of the image from the history of the tag to revert to")
+	flag.StringVar(&opts.reason, "reason", "", "Why the tag is reverted, recorded in an annotation on the tag")
+	flag.BoolVar(&opts.clear, "clear", false, "Clear a previous revert of the tag instead, so that it follows the promoted images again")
+	flag.BoolVar(&opts.dry, "dry-run", true, "Enable dry-run")
+	flag.Parse()
+	return opts
+}
+
+func (o *options) validate() error {
+	if o.tag == "" {
+		return errors.New("mandatory argument --tag was not set")
+	}
+	if o.clear {
+		if o.digest != "" || o.reason != "" {
+			return errors.New("--digest and --reason cannot be used with --clear")
+		}
+	} else {
+		for param, value := range map[string]string{
+			"--digest": o.digest,
+			"--reason": o.reason,
+		} {
+			if value == "" {
+				return fmt.Errorf("mandatory argument %s was not set", param)
+			}
+		}
+	}
+	_, err := parseTag(o.tag)
+	return err
+}
+
+func parseTag(raw string) (api.ImageStreamTagReference, error) {
+	namespace, rest := "", raw
+	if parts := strings.SplitN(raw, "/", 2); len(parts) == 2 {
+		namespace, rest = parts[0], parts[1]
+	}
+	parts := strings.SplitN(rest, ":", 2)
+	if namespace == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return api.ImageStreamTagReference{}, fmt.Errorf("--tag must be in the namespace/name:tag format, got %q", raw)
+	}
+	return api.ImageStreamTagReference{Namespace: namespace, Name: parts[0], Tag: parts[1]}, nil
+}
+
+// run reverts the tag, or clears its revert
+func run(ctx context.Context, client ctrlruntimeclient.Client, o *options) error {
+	tag, err := parseTag(o.tag)
+	if err != nil {
+		return err
+	}
+	if o.clear {
+		if err := release.ClearRevertedTag(ctx, client, tag); err != nil {
+			return err
+		}
+		logrus.Infof("Cleared the revert of %s", tag.ISTagName())
+		return nil
+	}
+	if err := release.RevertPromotedTag(ctx, client, tag, o.digest, o.reason); err != nil {
+		return err
+	}
+	logrus.Infof("Reverted %s to %s", tag.ISTagName(), o.digest)
+	return nil
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := opts()
+	if err := o.validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid parameters")
+	}
+
+	if err := imagev1.AddToScheme(scheme.Scheme); err != nil {
+		logrus.WithError(err).Fatal("Failed to add imagev1 to scheme")
+	}
+	config, err := util.LoadClusterConfig()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load cluster config")
+	}
+	client, err := ctrlruntimeclient.New(config, ctrlruntimeclient.Options{})
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to construct client")
+	}
+	if o.dry {
+		client = ctrlruntimeclient.NewDryRunClient(client)
+	}
+
+	if err := run(context.Background(), client, o); err != nil {
+		logrus.WithError(err).Fatal("Failed to revert promoted tag")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func init() {
+	if err := imagev1.AddToScheme(scheme.Scheme); err != nil {
+		panic(fmt.Sprintf("failed to add imagev1 to scheme: %v", err))
+	}
+}
+
+func TestRun(t *testing.T) {
+	stream := func(spec ...imagev1.TagReference) *imagev1.ImageStream {
+		return &imagev1.ImageStream{
+			ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8"},
+			Spec:       imagev1.ImageStreamSpec{Tags: spec},
+			Status: imagev1.ImageStreamStatus{Tags: []imagev1.NamedTagEventList{
+				{Tag: "cli", Items: []imagev1.TagEvent{{Image: "sha256:new"}, {Image: "sha256:old"}}},
+			}},
+		}
+	}
+	reverted := imagev1.TagReference{
+		Name:        "cli",
+		From:        &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "4.8@sha256:old"},
+		Annotations: map[string]string{api.ReleaseAnnotationRevertReason: "broke the installer"},
+	}
+	var testCases = []struct {
+		name          string
+		options       options
+		existing      *imagev1.ImageStream
+		dryRun        bool
+		expected      []imagev1.TagReference
+		expectedError bool
+	}{
+		{
+			name:     "tag is reverted to an image from its history",
+			options:  options{tag: "ocp/4.8:cli", digest: "sha256:old", reason: "broke the installer"},
+			existing: stream(),
+			expected: []imagev1.TagReference{reverted},
+		},
+		{
+			name:          "tag cannot be reverted to an image it never referenced",
+			options:       options{tag: "ocp/4.8:cli", digest: "sha256:other", reason: "broke the installer"},
+			existing:      stream(),
+			expectedError: true,
+		},
+		{
+			name:     "revert is only reported in a dry run",
+			options:  options{tag: "ocp/4.8:cli", digest: "sha256:old", reason: "broke the installer"},
+			existing: stream(),
+			dryRun:   true,
+		},
+		{
+			name:     "revert is cleared",
+			options:  options{tag: "ocp/4.8:cli", clear: true},
+			existing: stream(reverted),
+			expected: []imagev1.TagReference{{Name: "cli"}},
+		},
+		{
+			name:          "clearing a tag that is not reverted fails",
+			options:       options{tag: "ocp/4.8:cli", clear: true},
+			existing:      stream(imagev1.TagReference{Name: "cli"}),
+			expected:      []imagev1.TagReference{{Name: "cli"}},
+			expectedError: true,
+		},
+		{
+			name:          "tag must identify the imagestream",
+			options:       options{tag: "4.8:cli", digest: "sha256:old", reason: "broke the installer"},
+			existing:      stream(),
+			expectedError: true,
+		},
+		{
+			name:          "missing imagestream fails",
+			options:       options{tag: "ocp/4.9:cli", digest: "sha256:old", reason: "broke the installer"},
+			existing:      stream(),
+			expectedError: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var client ctrlruntimeclient.Client = fakectrlruntimeclient.NewClientBuilder().WithObjects(testCase.existing).Build()
+			reader := client
+			if testCase.dryRun {
+				client = ctrlruntimeclient.NewDryRunClient(client)
+			}
+			err := run(context.Background(), client, &testCase.options)
+			if (err != nil) != testCase.expectedError {
+				t.Fatalf("expected error: %v, got: %v", testCase.expectedError, err)
+			}
+			actual := &imagev1.ImageStream{}
+			if err := reader.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ocp", Name: "4.8"}, actual); err != nil {
+				t.Fatalf("could not get imagestream: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, actual.Spec.Tags); diff != "" {
+				t.Errorf("got incorrect tags: %v", diff)
+			}
+		})
+	}
+}
@@ -14,6 +14,11 @@ const (
 	GCSUploadCredentialsSecretMountPath = "/secrets/gcs"
 
 	ReleaseAnnotationSoftDelete = "release.openshift.io/soft-delete"
+	// ReleaseAnnotationPayloadEligibility is set on promoted tags to tell the release
+	// controller whether they may be included in release payloads
+	ReleaseAnnotationPayloadEligibility = "release.openshift.io/payload-eligibility"
+	// ReleaseAnnotationRevertReason records why a promoted tag was reverted to a prior image
+	ReleaseAnnotationRevertReason = "release.openshift.io/revert-reason"
 
 	// DPTPRequesterLabel is the label on a Kubernates CR whose value indicates the automated tool that requests the CR
 	DPTPRequesterLabel = "dptp.openshift.io/requester"
@@ -40,6 +40,18 @@ func TestInputImageStreamTagsFromResolvedConfig(cfg api.ReleaseBuildConfiguratio
 		}
 	}
 
+	if promotion := cfg.PromotionConfiguration; promotion != nil {
+		if verification := promotion.SignatureVerification; verification != nil {
+			insert(verification.Image, result)
+		}
+		if provenance := promotion.Provenance; provenance != nil {
+			insert(provenance.Image, result)
+		}
+		if scan := promotion.VulnerabilityScan; scan != nil {
+			insert(scan.Image, result)
+		}
+	}
+
 	var errs []error
 	for _, testStep := range cfg.Tests {
 		if testStep.MultiStageTestConfigurationLiteral != nil {
@@ -2,6 +2,8 @@ package api
 
 import (
 	"fmt"
+	"path"
+	"regexp"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -378,6 +380,32 @@ type ReleaseConfiguration struct {
 	UnresolvedRelease `json:",inline"`
 }
 
+// SplitAdditionalImageSource returns the image stream and tag an additional image is
+// promoted from. Sources that name no image stream are tags in the pipeline image stream.
+func SplitAdditionalImageSource(source string) (string, string) {
+	parts := strings.SplitN(source, ":", 2)
+	if len(parts) != 2 {
+		return PipelineImageStream, source
+	}
+	return parts[0], parts[1]
+}
+
+// MatchesExcludedImage determines whether the image is excluded by the pattern,
+// which is an exact image name, a glob or a regular expression wrapped in slashes
+func MatchesExcludedImage(pattern, image string) (bool, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expr, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false, err
+		}
+		return expr.MatchString(image), nil
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		return path.Match(pattern, image)
+	}
+	return pattern == image, nil
+}
+
 // PromotionConfiguration describes where images created by this
 // config should be published to. The release tag configuration
 // defines the inputs, while this defines the outputs.
@@ -398,14 +426,18 @@ type PromotionConfiguration struct {
 	// ExcludedImages are image names that will not be promoted.
 	// Exclusions are made before additional_images are included.
 	// Use exclusions when you want to build images for testing
-	// but not promote them afterwards.
+	// but not promote them afterwards. Besides exact names, an
+	// exclusion can be a glob like `tests-*` or a regular
+	// expression wrapped in slashes like `/^tests-.*$/`.
 	ExcludedImages []string `json:"excluded_images,omitempty"`
 
 	// AdditionalImages is a mapping of images to promote. The
 	// images will be taken from the pipeline image stream. The
 	// key is the name to promote as and the value is the source
 	// name. If you specify a tag that does not exist as the source
-	// the destination tag will not be created.
+	// the destination tag will not be created. A source in the
+	// stream:tag format, like `stable:cli`, is taken from that
+	// image stream in the test namespace instead.
 	AdditionalImages map[string]string `json:"additional_images,omitempty"`
 
 	// Disabled will no-op succeed instead of running the actual
@@ -426,8 +458,313 @@ type PromotionConfiguration struct {
 	// promotion does not imply output artifacts are being created
 	// for posterity.
 	DisableBuildCache bool `json:"disable_build_cache,omitempty"`
+
+	// Aliases are additional tags every promoted image gets in
+	// its destination image stream, so consumers can pin to an
+	// exact promoted build. The alias of a tag is the tag with
+	// the date of the promotion or the promoted commit appended.
+	Aliases []PromotionAlias `json:"aliases,omitempty"`
+
+	// Pruning, when set, deletes old aliases from the destination
+	// image streams after promotion. Aliases are only pruned when
+	// the job runs on the cluster serving the registry; otherwise,
+	// promoted-alias-pruner has to be run against that cluster.
+	Pruning *PruningConfiguration `json:"pruning,omitempty"`
+
+	// Mirror tunes how images are mirrored to the registries.
+	Mirror *MirrorConfiguration `json:"mirror,omitempty"`
+
+	// AdditionalRegistries are registries the promoted images
+	// are mirrored to besides the one they are promoted to, for
+	// example quay.io mirrors. Credentials for each registry are
+	// read from the push secret. A failure to push to one of them
+	// does not prevent promotion to the others.
+	AdditionalRegistries []string `json:"additional_registries,omitempty"`
+
+	// PushSecrets are dockerconfigjson secrets holding the credentials
+	// for registries the central push secret does not cover, like
+	// team-owned external registries. They are merged with the central
+	// push secret into the registry config of the promotion pods.
+	// They must exist in the test-credentials namespace.
+	PushSecrets []PushSecretReference `json:"push_secrets,omitempty"`
+
+	// Timeout is how long the promotion may take before it is
+	// aborted, so that a promotion of a huge payload fails with a
+	// clear reason instead of being killed by the job timeout.
+	Timeout *prowv1.Duration `json:"timeout,omitempty"`
+
+	// QuayProvisioning, when set, creates the repositories on quay.io
+	// that images are promoted to but that do not exist yet, as
+	// pushing to a missing repository fails.
+	QuayProvisioning *QuayProvisioningConfiguration `json:"quay_provisioning,omitempty"`
+
+	// TagViaAPI, when set, promotes images by creating image stream
+	// tags in the destination namespaces through the API instead of
+	// mirroring them in a pod. This is only possible when promoting
+	// to the registry of the cluster the job runs on; promotions to
+	// any other registry mirror the images as usual.
+	TagViaAPI bool `json:"tag_via_api,omitempty"`
+
+	// VulnerabilityScan, when set, scans the images before they
+	// are promoted and blocks the promotion of images with too
+	// many critical vulnerabilities.
+	VulnerabilityScan *VulnerabilityScanConfiguration `json:"vulnerability_scan,omitempty"`
+
+	// VerifyPushedDigests, when set, resolves every destination
+	// tag after promotion and fails the promotion unless the
+	// registry serves the image that was pushed to it.
+	VerifyPushedDigests bool `json:"verify_pushed_digests,omitempty"`
+
+	// SignatureVerification, when set, verifies after promotion that
+	// the promoted images are signed by one of the expected signers.
+	SignatureVerification *SignatureVerificationConfiguration `json:"signature_verification,omitempty"`
+
+	// Provenance, when set, attaches a SLSA provenance attestation
+	// describing the job that built them to every promoted image.
+	Provenance *ProvenanceConfiguration `json:"provenance,omitempty"`
+
+	// PayloadEligibility, when set, annotates every promoted tag in
+	// the destination image stream with whether the release controller
+	// may include it in release payloads, based on the results of the
+	// configured gates. Only image streams on the cluster the job runs
+	// on can be annotated, so it cannot be used with registry_override.
+	PayloadEligibility *PayloadEligibilityConfiguration `json:"payload_eligibility,omitempty"`
+
+	// SkipIfOnlyChanged is a regular expression matched against the
+	// files changed by all of the promoted commits. Promotion is
+	// skipped when every changed file matches, e.g. for changes that
+	// only touch documentation. When the range of promoted commits
+	// cannot be determined, the images are promoted.
+	SkipIfOnlyChanged string `json:"skip_if_only_changed,omitempty"`
+
+	// Archive, when set, additionally mirrors every promoted image
+	// into a long-term archive that survives pruning of the
+	// destination image streams.
+	Archive *PromotionArchiveConfiguration `json:"archive,omitempty"`
+}
+
+// PromotionCredentialsNamespace is the only namespace promotion reads secrets
+// from. ci-operator reads them with its own service account, so a configuration
+// must not be able to name any other secret on the cluster.
+const PromotionCredentialsNamespace = "test-credentials"
+
+// PushSecretReference identifies a secret holding registry credentials
+type PushSecretReference struct {
+	// Namespace is where the secret exists.
+	Namespace string `json:"namespace"`
+	// Name is the name of the secret.
+	Name string `json:"name"`
 }
 
+// QuayProvisioningConfiguration determines how missing
+// repositories on quay.io are created
+type QuayProvisioningConfiguration struct {
+	// TokenSecret is the secret holding the OAuth token for the
+	// Quay API under the `token` key. It must exist in the
+	// test-credentials namespace.
+	TokenSecret PushSecretReference `json:"token_secret"`
+
+	// Visibility of the created repositories, private by default.
+	Visibility QuayVisibility `json:"visibility,omitempty"`
+
+	// Teams are granted permissions on the created repositories.
+	Teams []QuayTeamPermission `json:"teams,omitempty"`
+}
+
+// QuayVisibility is the visibility of a repository on quay.io
+type QuayVisibility string
+
+const (
+	QuayVisibilityPublic  QuayVisibility = "public"
+	QuayVisibilityPrivate QuayVisibility = "private"
+)
+
+// QuayTeamPermission grants a team of the repository namespace
+// a role on the repository
+type QuayTeamPermission struct {
+	// Name is the name of the team.
+	Name string `json:"name"`
+	// Role is one of read, write or admin.
+	Role string `json:"role"`
+}
+
+// VulnerabilityScanConfiguration determines which vulnerabilities
+// block the promotion of an image.
+type VulnerabilityScanConfiguration struct {
+	// Image is the image stream tag of an image providing the
+	// trivy binary that scans the images.
+	Image ImageStreamTagReference `json:"image"`
+
+	// MaxCritical is the number of critical vulnerabilities an
+	// image may have and still be promoted. Defaults to 0.
+	MaxCritical int `json:"max_critical,omitempty"`
+
+	// Allowlist are IDs of vulnerabilities, e.g. CVE-2021-3449,
+	// that are not counted, for example because they do not
+	// affect the repository.
+	Allowlist []string `json:"allowlist,omitempty"`
+}
+
+// PromotionAlias is a kind of additional tag for promoted images.
+type PromotionAlias string
+
+const (
+	// PromotionAliasDate tags images with the UTC time of the
+	// promotion, e.g. latest-20210304-150405.
+	PromotionAliasDate PromotionAlias = "date"
+	// PromotionAliasCommit tags images with the SHA of the
+	// promoted commit.
+	PromotionAliasCommit PromotionAlias = "commit"
+)
+
+// PruningConfiguration determines which aliases of promoted tags are
+// kept. Aliases beyond the newest KeepLast or older than MaxAge are
+// deleted; the promoted tags themselves are never pruned.
+type PruningConfiguration struct {
+	// KeepLast is the number of newest aliases of every
+	// promoted tag that are kept.
+	KeepLast int `json:"keep_last,omitempty"`
+
+	// MaxAge is the age after which aliases are deleted.
+	MaxAge *prowv1.Duration `json:"max_age,omitempty"`
+}
+
+// MirrorConfiguration tunes the pods that mirror promoted images.
+type MirrorConfiguration struct {
+	// ShardSize is the largest number of images mirrored by a
+	// single pod. Larger promotions are split across pods that
+	// run in parallel. By default, a single pod mirrors all images.
+	ShardSize int `json:"shard_size,omitempty"`
+
+	// MaxPerRegistry is the number of concurrent requests
+	// allowed per registry. Defaults to 20.
+	MaxPerRegistry int `json:"max_per_registry,omitempty"`
+
+	// ContinueOnError keeps mirroring the remaining images when
+	// one of them fails. Defaults to true.
+	ContinueOnError *bool `json:"continue_on_error,omitempty"`
+
+	// Attempts is how many times an image is tried to be mirrored
+	// before the promotion fails. Defaults to 3.
+	Attempts int `json:"attempts,omitempty"`
+
+	// RetryDelay is how long to wait before the first retry. The
+	// delay doubles for every following retry. Defaults to 30s.
+	RetryDelay *prowv1.Duration `json:"retry_delay,omitempty"`
+}
+
+// PromotionArchiveConfiguration describes the archive promoted images are
+// mirrored to. Archived images are tagged with the date of the promotion and
+// their digest, so an archived tag is never overwritten.
+type PromotionArchiveConfiguration struct {
+	// Registry is the registry hosting the archive. Defaults
+	// to the registry images are promoted to.
+	Registry string `json:"registry,omitempty"`
+
+	// Namespace is the namespace in the archive registry that
+	// images are mirrored to.
+	Namespace string `json:"namespace"`
+
+	// RetentionDays is how long archived images must be kept.
+	// It is recorded in the promotion manifest for the tooling
+	// that maintains the archive.
+	RetentionDays int `json:"retention_days,omitempty"`
+}
+
+// ProvenanceConfiguration determines how provenance attestations
+// are signed.
+type ProvenanceConfiguration struct {
+	// Image is the image stream tag of an image providing the
+	// cosign binary that attaches the attestations.
+	Image ImageStreamTagReference `json:"image"`
+
+	// Key is the cosign reference of the key the attestations are
+	// signed with, e.g. a KMS URI.
+	Key string `json:"key"`
+
+	// Credentials are secrets mounted for cosign, e.g. the cloud
+	// credentials needed to sign with a KMS key. They must exist in
+	// the test-credentials namespace.
+	Credentials []CredentialReference `json:"credentials,omitempty"`
+
+	// Env are environment variables set for cosign, e.g.
+	// GOOGLE_APPLICATION_CREDENTIALS pointing at a file in one of
+	// the mounted credentials.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// PayloadEligibilityConfiguration determines how promoted tags are
+// annotated for inclusion in release payloads.
+type PayloadEligibilityConfiguration struct {
+	// Gates are the checks run during promotion that decide whether
+	// a promoted image may be included in release payloads. Images
+	// that fail any of them are annotated as experimental, all others
+	// as eligible.
+	Gates []PayloadEligibilityGate `json:"gates"`
+}
+
+// PayloadEligibilityGate is a check run during promotion whose results
+// decide the payload eligibility of the promoted images
+type PayloadEligibilityGate string
+
+const (
+	// PayloadEligibilityGateSignatureVerification marks images that fail
+	// the signature verification as experimental. This only makes a
+	// difference with the warn policy, as failures block the promotion
+	// otherwise.
+	PayloadEligibilityGateSignatureVerification PayloadEligibilityGate = "signature_verification"
+)
+
+// PayloadEligibility is the value of the ReleaseAnnotationPayloadEligibility annotation
+type PayloadEligibility string
+
+const (
+	// PayloadEligibilityEligible marks a tag that may be included in release payloads
+	PayloadEligibilityEligible PayloadEligibility = "eligible"
+	// PayloadEligibilityExperimental marks a tag that must not be included in release payloads
+	PayloadEligibilityExperimental PayloadEligibility = "experimental"
+)
+
+// SignatureVerificationConfiguration describes the cosign policy that
+// promoted images are verified against at their destination.
+type SignatureVerificationConfiguration struct {
+	// Image is the image stream tag of an image providing the
+	// cosign binary that verifies the signatures.
+	Image ImageStreamTagReference `json:"image"`
+
+	// Identities are the accepted signers. An image passes
+	// verification when it is signed by any one of them.
+	Identities []SignerIdentity `json:"identities"`
+
+	// Policy determines what happens when an image fails
+	// verification. Can be: enforce (default) or warn.
+	// Images are verified right after they are pushed to the
+	// promotion registry. With enforce, a failure stops the
+	// promotion before aliases, archive tags and additional
+	// registries are pushed, but the images that were already
+	// pushed to the promotion registry stay there.
+	Policy VerificationPolicy `json:"policy,omitempty"`
+}
+
+// SignerIdentity identifies a keyless cosign signer
+type SignerIdentity struct {
+	// Identity is the expected subject of the signing certificate
+	Identity string `json:"identity"`
+	// Issuer is the expected OIDC issuer of the signing certificate
+	Issuer string `json:"issuer"`
+}
+
+// VerificationPolicy determines how failed signature verification is handled
+type VerificationPolicy string
+
+const (
+	// VerificationPolicyEnforce fails the promotion when verification fails,
+	// before the images are published anywhere but the promotion registry
+	VerificationPolicyEnforce VerificationPolicy = "enforce"
+	// VerificationPolicyWarn only reports images that fail verification
+	VerificationPolicyWarn VerificationPolicy = "warn"
+)
+
 // StepConfiguration holds one step configuration.
 // Only one of the fields in this can be non-null.
 type StepConfiguration struct {
@@ -1451,6 +1788,11 @@ type ProjectDirectoryImageBuildStepConfiguration struct {
 	// promoted unless explicitly targeted. Use for builds which
 	// are invoked only when testing certain parts of the repo.
 	Optional bool `json:"optional,omitempty"`
+
+	// Promote, when set to false, excludes the image from promotion
+	// as if it were listed in excluded_images. The image is still
+	// built and can be used in tests.
+	Promote *bool `json:"promote,omitempty"`
 }
 
 // ProjectDirectoryImageBuildInputs holds inputs for an image build from the repo under test
@@ -343,3 +343,32 @@ func TestInputImageTagStepConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchesExcludedImage(t *testing.T) {
+	var testCases = []struct {
+		pattern       string
+		image         string
+		expected      bool
+		expectedError bool
+	}{
+		{pattern: "tests", image: "tests", expected: true},
+		{pattern: "tests", image: "tests-unit"},
+		{pattern: "tests-*", image: "tests-unit", expected: true},
+		{pattern: "tests-?", image: "tests-unit"},
+		{pattern: "/^tests-(unit|e2e)$/", image: "tests-e2e", expected: true},
+		{pattern: "/^tests-(unit|e2e)$/", image: "tests-e2e-aws"},
+		{pattern: "tests-[", image: "tests-unit", expectedError: true},
+		{pattern: "/(/", image: "tests", expectedError: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.pattern+" "+testCase.image, func(t *testing.T) {
+			matches, err := MatchesExcludedImage(testCase.pattern, testCase.image)
+			if (err != nil) != testCase.expectedError {
+				t.Errorf("expected error: %v, got: %v", testCase.expectedError, err)
+			}
+			if matches != testCase.expected {
+				t.Errorf("expected match: %v, got: %v", testCase.expected, matches)
+			}
+		})
+	}
+}
@@ -208,7 +208,10 @@ func GenerateJobs(configSpec *cioperatorapi.ReleaseBuildConfiguration, info *Pro
 	imageTargets := sets.NewString()
 	if configSpec.PromotionConfiguration != nil {
 		for additional := range configSpec.PromotionConfiguration.AdditionalImages {
-			imageTargets.Insert(configSpec.PromotionConfiguration.AdditionalImages[additional])
+			// images from other streams are not built by the job, so they are no targets
+			if stream, tag := cioperatorapi.SplitAdditionalImageSource(configSpec.PromotionConfiguration.AdditionalImages[additional]); stream == cioperatorapi.PipelineImageStream {
+				imageTargets.Insert(tag)
+			}
 		}
 	}
 
@@ -322,39 +322,52 @@ func (s *multiStageTestStep) createSharedDirSecret(ctx context.Context) error {
 
 func (s *multiStageTestStep) createCredentials() error {
 	logrus.Debugf("Creating multi-stage test credentials for %q", s.name)
-	toCreate := map[string]*coreapi.Secret{}
+	var credentials []api.CredentialReference
 	for _, step := range append(s.pre, append(s.test, s.post...)...) {
-		for _, credential := range step.Credentials {
-			// we don't want secrets imported from separate namespaces to collide
-			// but we want to keep them generally recognizable for debugging, and the
-			// chance we get a second-level collision (ns-a, name) and (ns, a-name) is
-			// small, so we can get away with this string prefixing
-			name := fmt.Sprintf("%s-%s", credential.Namespace, credential.Name)
-			raw := &coreapi.Secret{}
-			if err := s.client.Get(context.TODO(), ctrlruntimeclient.ObjectKey{Namespace: credential.Namespace, Name: credential.Name}, raw); err != nil {
-				return fmt.Errorf("could not read source credential: %w", err)
-			}
-			toCreate[name] = &coreapi.Secret{
-				TypeMeta: raw.TypeMeta,
-				ObjectMeta: meta.ObjectMeta{
-					Name:      name,
-					Namespace: s.jobSpec.Namespace(),
-				},
-				Type:       raw.Type,
-				Data:       raw.Data,
-				StringData: raw.StringData,
-			}
+		credentials = append(credentials, step.Credentials...)
+	}
+	return CopyCredentials(context.TODO(), s.client, s.jobSpec.Namespace(), credentials)
+}
+
+// CopyCredentials copies the referenced secrets into the namespace, so that they
+// can be mounted into pods there with AddCredentials
+func CopyCredentials(ctx context.Context, client ctrlruntimeclient.Client, namespace string, credentials []api.CredentialReference) error {
+	toCreate := map[string]*coreapi.Secret{}
+	for _, credential := range credentials {
+		name := credentialSecretName(credential)
+		raw := &coreapi.Secret{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: credential.Namespace, Name: credential.Name}, raw); err != nil {
+			return fmt.Errorf("could not read source credential: %w", err)
+		}
+		toCreate[name] = &coreapi.Secret{
+			TypeMeta: raw.TypeMeta,
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Type:       raw.Type,
+			Data:       raw.Data,
+			StringData: raw.StringData,
 		}
 	}
 
 	for name := range toCreate {
-		if err := s.client.Create(context.TODO(), toCreate[name]); err != nil && !kerrors.IsAlreadyExists(err) {
+		if err := client.Create(ctx, toCreate[name]); err != nil && !kerrors.IsAlreadyExists(err) {
 			return fmt.Errorf("could not create source credential: %w", err)
 		}
 	}
 	return nil
 }
 
+// credentialSecretName is the name of the copy of a credential
+func credentialSecretName(credential api.CredentialReference) string {
+	// we don't want secrets imported from separate namespaces to collide
+	// but we want to keep them generally recognizable for debugging, and the
+	// chance we get a second-level collision (ns-a, name) and (ns, a-name) is
+	// small, so we can get away with this string prefixing
+	return fmt.Sprintf("%s-%s", credential.Namespace, credential.Name)
+}
+
 func (s *multiStageTestStep) createCommandConfigMaps(ctx context.Context) error {
 	logrus.Debugf("Creating multi-stage test commands configmap for %q", s.name)
 	data := make(map[string]string)
@@ -576,7 +589,7 @@ func (s *multiStageTestStep) generatePods(steps []api.LiteralTestStep, env []cor
 			addCliInjector(imagestream, pod)
 		}
 		addSharedDirSecret(s.name, pod)
-		addCredentials(step.Credentials, pod)
+		AddCredentials(step.Credentials, pod)
 		if step.RunAsScript != nil && *step.RunAsScript {
 			addCommandScript(commandConfigMapForTest(s.name), pod)
 		}
@@ -709,9 +722,11 @@ func addSharedDirSecret(secret string, pod *coreapi.Pod) {
 	})
 }
 
-func addCredentials(credentials []api.CredentialReference, pod *coreapi.Pod) {
+// AddCredentials mounts the copies of the credentials made by CopyCredentials
+// into the first container of the pod
+func AddCredentials(credentials []api.CredentialReference, pod *coreapi.Pod) {
 	for _, credential := range credentials {
-		name := fmt.Sprintf("%s-%s", credential.Namespace, credential.Name)
+		name := credentialSecretName(credential)
 		volumeName := volumeName(credential.Namespace, credential.Name)
 		pod.Spec.Volumes = append(pod.Spec.Volumes, coreapi.Volume{
 			Name: volumeName,
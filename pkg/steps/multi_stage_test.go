@@ -629,7 +629,7 @@ func TestAddCredentials(t *testing.T) {
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			addCredentials(testCase.credentials, &testCase.pod)
+			AddCredentials(testCase.credentials, &testCase.pod)
 			if !equality.Semantic.DeepEqual(testCase.pod, testCase.expected) {
 				t.Errorf("%s: got incorrect Pod: %s", testCase.name, cmp.Diff(testCase.pod, testCase.expected))
 			}
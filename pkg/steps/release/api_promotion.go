@@ -0,0 +1,83 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/retry"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+)
+
+// tagImages promotes the images by pointing the destination image stream tags at them,
+// which avoids scheduling a mirror pod. The destination must be on the cluster of the job.
+func (s *promotionStep) tagImages(ctx context.Context, imageMirrorTarget map[string]string) error {
+	sources := make([]string, 0, len(imageMirrorTarget))
+	for src := range imageMirrorTarget {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	logrus.Infof("Tagging %d images through the API", len(sources))
+	var errs []error
+	for _, src := range sources {
+		istag, err := imageStreamTagFor(src, imageMirrorTarget[src])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			err := s.client.Create(ctx, istag.DeepCopy())
+			if kerrors.IsAlreadyExists(err) {
+				existing := &imagev1.ImageStreamTag{}
+				if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: istag.Namespace, Name: istag.Name}, existing); err != nil {
+					return err
+				}
+				existing.Tag = istag.Tag
+				err = s.client.Update(ctx, existing)
+			}
+			return err
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("could not tag %s: %w", imageMirrorTarget[src], err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// imageStreamTagFor builds the image stream tag for a destination pull spec in the
+// registry/namespace/name:tag format. It references the source image stream image by
+// digest with a local reference policy, so the destination stream keeps the image after
+// the namespace of the job is gone.
+func imageStreamTagFor(src, dst string) (*imagev1.ImageStreamTag, error) {
+	parts := strings.SplitN(dst, "/", 3)
+	if len(parts) != 3 || !strings.Contains(parts[2], ":") {
+		return nil, fmt.Errorf("could not determine the image stream tag for destination %s", dst)
+	}
+	sourceParts := strings.Split(src, "/")
+	if len(sourceParts) < 3 || !strings.Contains(sourceParts[len(sourceParts)-1], "@sha256:") {
+		return nil, fmt.Errorf("could not determine the image stream image for source %s", src)
+	}
+	return &imagev1.ImageStreamTag{
+		ObjectMeta: meta.ObjectMeta{
+			Namespace: parts[1],
+			Name:      parts[2],
+		},
+		Tag: &imagev1.TagReference{
+			Name: parts[2][strings.LastIndex(parts[2], ":")+1:],
+			From: &coreapi.ObjectReference{
+				Kind:      "ImageStreamImage",
+				Namespace: sourceParts[len(sourceParts)-2],
+				Name:      sourceParts[len(sourceParts)-1],
+			},
+			ReferencePolicy: imagev1.TagReferencePolicy{Type: imagev1.LocalTagReferencePolicy},
+		},
+	}, nil
+}
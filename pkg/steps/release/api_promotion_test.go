@@ -0,0 +1,87 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
+)
+
+func init() {
+	if err := imagev1.AddToScheme(scheme.Scheme); err != nil {
+		panic(fmt.Sprintf("failed to add imagev1 to scheme: %v", err))
+	}
+}
+
+func TestTagImages(t *testing.T) {
+	existing := &imagev1.ImageStreamTag{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8:cli"},
+		Tag: &imagev1.TagReference{
+			Name: "cli",
+			From: &coreapi.ObjectReference{Kind: "DockerImage", Name: "registry.ci.openshift.org/ci-op-0/pipeline@sha256:old"},
+			// a revert of the tag is cleared when it is promoted again
+			Annotations: map[string]string{"release.openshift.io/revert-reason": "broke the installer"},
+		},
+	}
+	client := fakectrlruntimeclient.NewFakeClient(existing)
+	s := &promotionStep{
+		configuration: &api.ReleaseBuildConfiguration{PromotionConfiguration: &api.PromotionConfiguration{TagViaAPI: true}},
+		client:        steps.NewPodClient(loggingclient.New(client), nil, nil),
+		tagViaAPI:     true,
+	}
+	imageMirrorTarget := map[string]string{
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:cli": "registry.ci.openshift.org/ocp/4.8:cli",
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:new": "registry.ci.openshift.org/ocp/4.8:new",
+	}
+	if err := s.promote(context.Background(), "promotion", imageMirrorTarget); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for src, dst := range map[string]string{
+		"pipeline@sha256:cli": "4.8:cli",
+		"pipeline@sha256:new": "4.8:new",
+	} {
+		istag := &imagev1.ImageStreamTag{}
+		if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ocp", Name: dst}, istag); err != nil {
+			t.Fatalf("could not get %s: %v", dst, err)
+		}
+		expected := &coreapi.ObjectReference{Kind: "ImageStreamImage", Namespace: "ci-op-1", Name: src}
+		if diff := cmp.Diff(expected, istag.Tag.From); diff != "" {
+			t.Errorf("%s points at the wrong image: %s", dst, diff)
+		}
+		if reason, reverted := istag.Tag.Annotations["release.openshift.io/revert-reason"]; reverted {
+			t.Errorf("%s is still reverted: %s", dst, reason)
+		}
+	}
+}
+
+func TestImageStreamTagFor(t *testing.T) {
+	if _, err := imageStreamTagFor("registry.ci.openshift.org/ci-op-1/pipeline@sha256:cli", "registry.ci.openshift.org/ocp"); err == nil {
+		t.Error("expected an error for a destination without a tag, got none")
+	}
+	istag, err := imageStreamTagFor("registry.ci.openshift.org/ci-op-1/pipeline@sha256:cli", "registry.ci.openshift.org/ocp/4.8:cli")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if istag.Namespace != "ocp" || istag.Name != "4.8:cli" || istag.Tag.Name != "cli" {
+		t.Errorf("got incorrect image stream tag: %s/%s (tag %s)", istag.Namespace, istag.Name, istag.Tag.Name)
+	}
+	if istag.Tag.ReferencePolicy.Type != imagev1.LocalTagReferencePolicy {
+		t.Errorf("expected a local reference policy, got %s", istag.Tag.ReferencePolicy.Type)
+	}
+	if _, err := imageStreamTagFor("registry.ci.openshift.org/ci-op-1/pipeline:cli", "registry.ci.openshift.org/ocp/4.8:cli"); err == nil {
+		t.Error("expected an error for a source without a digest, got none")
+	}
+}
@@ -0,0 +1,113 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// verifyPushedDigests resolves every destination the images were mirrored to and fails
+// when the registry does not serve the digest that was pushed, e.g. because it silently
+// dropped or has not yet completed the push
+func (s *promotionStep) verifyPushedDigests(ctx context.Context, imageMirrorTarget map[string]string) error {
+	expected := expectedDigests(imageMirrorTarget)
+	logrus.Infof("Verifying the digests of %d promoted images", len(expected))
+	pod, err := s.runPod(ctx, getDigestVerificationPod(expected, s.jobSpec.Namespace()))
+	if err != nil {
+		if mismatches := digestMismatches(terminationMessage(pod)); len(mismatches) != 0 {
+			return fmt.Errorf("promoted images do not match the pushed digests: %s", strings.Join(mismatches, "; "))
+		}
+		return fmt.Errorf("unable to verify the digests of promoted images: %w", err)
+	}
+	return nil
+}
+
+// expectedDigests maps every destination to the digest of the pipeline image pushed to it
+func expectedDigests(imageMirrorTarget map[string]string) map[string]string {
+	expected := map[string]string{}
+	for src, dst := range imageMirrorTarget {
+		if i := strings.LastIndex(src, "@"); i != -1 {
+			expected[dst] = src[i+1:]
+		}
+	}
+	return expected
+}
+
+// digestMismatches formats the mismatches the verification pod reported, one per line
+// as the destination, the expected digest and the digest the registry served
+func digestMismatches(message string) []string {
+	var mismatches []string
+	for _, line := range strings.Split(strings.TrimSpace(message), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !strings.HasPrefix(fields[1], "sha256:") {
+			continue
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s: expected %s, registry serves %s", fields[0], fields[1], fields[2]))
+	}
+	return mismatches
+}
+
+// getDigestVerificationPod returns a pod that resolves every destination and reports
+// the ones whose digest differs from the expected one in its termination message
+func getDigestVerificationPod(expected map[string]string, namespace string) *coreapi.Pod {
+	destinations := make([]string, 0, len(expected))
+	for dst := range expected {
+		destinations = append(destinations, dst)
+	}
+	sort.Strings(destinations)
+	var checks []string
+	for _, dst := range destinations {
+		checks = append(checks, fmt.Sprintf("verify %s %s", shellQuote(dst), shellQuote(expected[dst])))
+	}
+	script := fmt.Sprintf(`failed=0
+verify() {
+  actual=$(oc image info --registry-config=%s "$1" | awk '$1 == "Digest:" {print $2}')
+  if [ "$actual" != "$2" ]; then
+    echo "$1 $2 ${actual:-nothing}" | tee -a /dev/termination-log
+    failed=1
+  fi
+}
+%s
+exit $failed`, filepath.Join(api.RegistryPushCredentialsCICentralSecretMountPath, coreapi.DockerConfigJsonKey), strings.Join(checks, "\n"))
+	return &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "promotion-digest-verification",
+			Namespace: namespace,
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			Containers: []coreapi.Container{
+				{
+					Name:    "digest-verification",
+					Image:   fmt.Sprintf("%s/ocp/4.8:cli", api.DomainForService(api.ServiceRegistry)),
+					Command: []string{"/bin/sh", "-c"},
+					Args:    []string{script},
+					VolumeMounts: []coreapi.VolumeMount{
+						{
+							Name:      "push-secret",
+							MountPath: api.RegistryPushCredentialsCICentralSecretMountPath,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Volumes: []coreapi.Volume{
+				{
+					Name: "push-secret",
+					VolumeSource: coreapi.VolumeSource{
+						Secret: &coreapi.SecretVolumeSource{SecretName: promotionRegistryConfigSecret},
+					},
+				},
+			},
+		},
+	}
+}
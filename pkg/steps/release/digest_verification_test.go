@@ -0,0 +1,43 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestExpectedDigests(t *testing.T) {
+	targets := map[string]string{
+		"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:aaa": "quay.io/ocp/4.8:a",
+		"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:bbb": "quay.io/ocp/4.8:b",
+		"registry.svc.ci.openshift.org/ci-op-1/pipeline:src":        "quay.io/ocp/4.8:src",
+	}
+	expected := map[string]string{
+		"quay.io/ocp/4.8:a": "sha256:aaa",
+		"quay.io/ocp/4.8:b": "sha256:bbb",
+	}
+	if diff := cmp.Diff(expected, expectedDigests(targets)); diff != "" {
+		t.Errorf("got incorrect expected digests: %v", diff)
+	}
+}
+
+func TestDigestMismatches(t *testing.T) {
+	message := "quay.io/ocp/4.8:a sha256:aaa sha256:000\nquay.io/ocp/4.8:b sha256:bbb nothing\nerror: unrelated output\n"
+	expected := []string{
+		"quay.io/ocp/4.8:a: expected sha256:aaa, registry serves sha256:000",
+		"quay.io/ocp/4.8:b: expected sha256:bbb, registry serves nothing",
+	}
+	if diff := cmp.Diff(expected, digestMismatches(message)); diff != "" {
+		t.Errorf("got incorrect mismatches: %v", diff)
+	}
+}
+
+func TestGetDigestVerificationPod(t *testing.T) {
+	expected := map[string]string{
+		"registry.ci.openshift.org/ocp/4.8:b": "sha256:bbb",
+		"registry.ci.openshift.org/ocp/4.8:a": "sha256:aaa",
+	}
+	testhelper.CompareWithFixture(t, getDigestVerificationPod(expected, "ci-op-zyvwvffx"))
+}
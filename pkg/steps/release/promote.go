@@ -2,16 +2,27 @@ package release
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/secretutil"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	imagev1 "github.com/openshift/api/image/v1"
@@ -28,7 +39,16 @@ type promotionStep struct {
 	requiredImages sets.String
 	jobSpec        *api.JobSpec
 	client         steps.PodClient
+	pullSecret     *coreapi.Secret
 	pushSecret     *coreapi.Secret
+	// inputSteps are the build steps, whose inputs are recorded in the provenance
+	inputSteps []api.Step
+	// censor redacts secrets from the artifacts of the promotion
+	censor  secretutil.Censorer
+	probe   registryProbe
+	metrics *promotionMetricsRecorder
+	// tagViaAPI is set when the images are promoted through the API instead of mirror pods
+	tagViaAPI bool
 }
 
 func targetName(config api.PromotionConfiguration) string {
@@ -45,7 +65,21 @@ func (s *promotionStep) Inputs() (api.InputDefinition, error) {
 func (*promotionStep) Validate() error { return nil }
 
 func (s *promotionStep) Run(ctx context.Context) error {
-	return results.ForReason("promoting_images").ForError(s.run(ctx))
+	s.metrics = &promotionMetricsRecorder{}
+	start := time.Now()
+	timeout := s.configuration.PromotionConfiguration.Timeout
+	if timeout != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout.Duration)
+		defer cancel()
+	}
+	err := s.run(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && timeout != nil {
+		err = results.ForReason("promotion_timeout").WithError(err).Errorf("promotion did not finish within %s: %v", timeout.Duration, err)
+	}
+	err = results.ForReason("promoting_images").ForError(err)
+	s.metrics.save(s.censor, time.Since(start), err)
+	return err
 }
 
 func (s *promotionStep) run(ctx context.Context) error {
@@ -55,27 +89,909 @@ func (s *promotionStep) run(ctx context.Context) error {
 		return nil
 	}
 
+	if pattern := s.configuration.PromotionConfiguration.SkipIfOnlyChanged; pattern != "" {
+		skip, err := s.onlyMatchingFilesChanged(ctx, pattern)
+		if err != nil {
+			logrus.WithError(err).Warn("Could not determine the files changed by the promoted commits, promoting anyway.")
+		} else if skip {
+			logrus.Infof("Skipping promotion: every changed file matches %q.", pattern)
+			return nil
+		}
+	}
+
 	logrus.Infof("Promoting tags to %s: %s", targetName(*s.configuration.PromotionConfiguration), strings.Join(names.List(), ", "))
 	pipeline := &imagev1.ImageStream{}
 	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{
 		Namespace: s.jobSpec.Namespace(),
 		Name:      api.PipelineImageStream,
 	}, pipeline); err != nil {
-		return fmt.Errorf("could not resolve pipeline imagestream: %w", err)
+		return results.ForReason("resolving_pipeline").WithError(err).Errorf("could not resolve pipeline imagestream: %v", err)
+	}
+	pipeline, err := s.withImagesFromOtherStreams(ctx, pipeline, tags)
+	if err != nil {
+		return results.ForReason("resolving_additional_images").ForError(err)
 	}
+	images := indexPipeline(pipeline)
 
-	imageMirrorTarget := getImageMirrorTarget(tags, pipeline, registryDomain(s.configuration.PromotionConfiguration))
-	if len(imageMirrorTarget) == 0 {
+	registry := registryDomain(s.configuration.PromotionConfiguration)
+	buildCache := api.BuildCacheFor(s.configuration.Metadata)
+	rehearsal := isRehearsal(s.jobSpec)
+	if rehearsal {
+		registry = publicRegistryHost(pipeline)
+		if registry == "" {
+			logrus.Warn("Could not determine the registry of the job namespace, skipping rehearsed promotion.")
+			return nil
+		}
+		logrus.Infof("Rehearsing promotion into %s/%s instead of the configured destination.", registry, s.jobSpec.Namespace())
+		tags = rehearsalTags(tags, s.jobSpec.Namespace())
+		buildCache.Namespace = s.jobSpec.Namespace()
+	}
+	tags, buildCacheTags := splitBuildCache(tags, buildCache)
+
+	skipped := skippedTags(tags, images)
+	for _, skipped := range skipped {
+		logrus.Warnf("Not promoting %s/%s:%s, the pipeline holds no image for %s.", skipped.Namespace, skipped.Name, skipped.Tag, skipped.Source)
+	}
+	s.metrics.setImages(len(tags), len(skipped))
+	imageMirrorTarget := getImageMirrorTarget(tags, images, registry)
+	buildCacheMirrorTarget := getImageMirrorTarget(buildCacheTags, images, registry)
+	if len(imageMirrorTarget) == 0 && len(buildCacheMirrorTarget) == 0 {
 		logrus.Info("Nothing to promote, skipping...")
 		return nil
 	}
 
-	if _, err := steps.RunPod(ctx, s.client, getPromotionPod(imageMirrorTarget, s.jobSpec.Namespace())); err != nil {
-		return fmt.Errorf("unable to run promotion pod: %w", err)
+	// the destination streams can only be read on the cluster serving the registry, as a
+	// copy of them on another cluster may be stale and hide images that need to be pushed
+	if onJobCluster(pipeline, registry) {
+		if unchanged := unchangedTags(tags, images, s.destinationStreams(ctx, tags)); unchanged.Len() != 0 {
+			logrus.Infof("Not mirroring tags that already point to the promoted images: %s", strings.Join(unchanged.List(), ", "))
+			s.metrics.setUnchanged(unchanged.Len())
+			imageMirrorTarget = getImageMirrorTarget(tagsExcept(tags, unchanged), images, registry)
+		}
+	}
+
+	pushSecrets, err := s.promotionPushSecrets(ctx, rehearsal)
+	if err != nil {
+		return results.ForReason("registry_credentials").ForError(err)
+	}
+	registryConfig, err := assembleRegistryConfig(s.pullSecret, pushSecrets...)
+	if err != nil {
+		return results.ForReason("registry_credentials").WithError(err).Errorf("could not assemble registry credentials: %v", err)
+	}
+	if err := ensureRegistryConfigSecret(ctx, s.client, s.jobSpec.Namespace(), registryConfig); err != nil {
+		return results.ForReason("registry_credentials").ForError(err)
+	}
+
+	if s.configuration.PromotionConfiguration.TagViaAPI {
+		if onJobCluster(pipeline, registry) {
+			s.tagViaAPI = true
+		} else {
+			logrus.Warnf("Not promoting through the API: registry %s is not the registry of the cluster the job runs on, mirroring instead.", registry)
+		}
+	}
+
+	if s.probe == nil {
+		s.probe = defaultRegistryProbe(registryConfig)
+	}
+	if err := s.probe(ctx, registry); err != nil {
+		return results.ForReason("registry_unavailable").WithError(err).Errorf("registry %s failed its health check: %v", registry, err)
+	}
+
+	suffixes := aliasSuffixes(s.configuration.PromotionConfiguration.Aliases, s.jobSpec, time.Now())
+	if quay := s.configuration.PromotionConfiguration.QuayProvisioning; quay != nil && !rehearsal {
+		imageMirrorTargets := pushedMirrorTargets(*s.configuration.PromotionConfiguration, tags, images, registry, imageMirrorTarget, suffixes, time.Now())
+		if err := s.provisionQuayRepositories(ctx, quayRepositories(imageMirrorTargets...), *quay); err != nil {
+			return results.ForReason("provisioning_repositories").ForError(err)
+		}
+	}
+
+	// tags that are unchanged in the destination are not mirrored there, but are still
+	// pushed as aliases, archive tags and to additional registries, so all are scanned
+	if scan := s.configuration.PromotionConfiguration.VulnerabilityScan; scan != nil {
+		if err := s.scanImages(ctx, getImageMirrorTarget(tags, images, registry), *scan); err != nil {
+			return results.ForReason("scanning_images").ForError(err)
+		}
+	}
+
+	if len(imageMirrorTarget) != 0 {
+		if err := s.promote(ctx, "promotion", imageMirrorTarget); err != nil {
+			return results.ForReason("mirroring_images").WithError(err).Errorf("unable to run promotion pod: %v", err)
+		}
+		// promoting through the API replaces the spec of the tags, which already clears reverts
+		if !s.tagViaAPI && onJobCluster(pipeline, registry) {
+			if err := s.clearPromotedReverts(ctx, tags, registry, imageMirrorTarget); err != nil {
+				return results.ForReason("clearing_reverts").ForError(err)
+			}
+		}
+	}
+	if s.configuration.PromotionConfiguration.VerifyPushedDigests && len(imageMirrorTarget) != 0 {
+		if err := s.verifyPushedDigests(ctx, imageMirrorTarget); err != nil {
+			return results.ForReason("verifying_digests").ForError(err)
+		}
+	}
+
+	// signatures are verified at the primary destination before the images are published
+	// anywhere else, so that an enforced verification keeps unverified images from spreading
+	failedGates := map[api.PayloadEligibilityGate]sets.String{}
+	if verification := s.configuration.PromotionConfiguration.SignatureVerification; verification != nil && len(imageMirrorTarget) != 0 {
+		unverified, err := s.verifySignatures(ctx, imageMirrorTarget, *verification)
+		if err != nil {
+			return results.ForReason("verifying_signatures").ForError(err)
+		}
+		failedGates[api.PayloadEligibilityGateSignatureVerification] = unverified
+	}
+
+	for i, suffix := range suffixes {
+		if err := s.promote(ctx, fmt.Sprintf("promotion-alias-%d", i), getImageMirrorTarget(aliasTags(tags, suffix), images, registry)); err != nil {
+			return results.ForReason("mirroring_aliases").WithError(err).Errorf("unable to tag promoted images with aliases: %v", err)
+		}
+	}
+	if provenance := s.configuration.PromotionConfiguration.Provenance; provenance != nil && !rehearsal && len(imageMirrorTarget) != 0 {
+		if err := s.attestProvenance(ctx, tags, images, registry, *provenance); err != nil {
+			return results.ForReason("attesting_provenance").ForError(err)
+		}
+	}
+
+	var archived []ArchivedImage
+	if archive := s.configuration.PromotionConfiguration.Archive; archive != nil && !rehearsal && len(imageMirrorTarget) != 0 {
+		if archived, err = s.archiveImages(ctx, tags, images, imageMirrorTarget, registry, *archive); err != nil {
+			return results.ForReason("archiving_images").ForError(err)
+		}
+	}
+	var additionalRegistries []RegistryStatus
+	if !rehearsal {
+		additionalRegistries = s.mirrorToAdditionalRegistries(ctx, tags, images)
+	}
+
+	manifest := promotionManifestFor(s.jobSpec, tags, images, registry)
+	manifest.Rehearsal = rehearsal
+	for i := range manifest.Tags {
+		for _, suffix := range suffixes {
+			manifest.Tags[i].Aliases = append(manifest.Tags[i].Aliases, fmt.Sprintf("%s-%s", manifest.Tags[i].PullSpec, suffix))
+		}
+	}
+	manifest.BuildCache = s.pushBuildCache(ctx, buildCacheMirrorTarget)
+	manifest.AdditionalRegistries = additionalRegistries
+	manifest.Archive = archived
+	savePromotionManifest(s.censor, manifest)
+
+	if eligibility := s.configuration.PromotionConfiguration.PayloadEligibility; eligibility != nil && len(imageMirrorTarget) != 0 {
+		if !onJobCluster(pipeline, registry) {
+			logrus.Warnf("Not annotating payload eligibility: registry %s is not the registry of the cluster the job runs on.", registry)
+		} else if err := s.stampPayloadEligibility(ctx, tags, registry, imageMirrorTarget, experimentalDestinations(*eligibility, failedGates)); err != nil {
+			return results.ForReason("stamping_payload_eligibility").ForError(err)
+		}
+	}
+
+	if pruning := s.configuration.PromotionConfiguration.Pruning; pruning != nil && !rehearsal {
+		if !onJobCluster(pipeline, registry) {
+			logrus.Warnf("Not pruning aliases: registry %s is not the registry of the cluster the job runs on, use promoted-alias-pruner against that cluster instead.", registry)
+		} else {
+			s.pruneAliases(ctx, tags, *pruning)
+		}
+	}
+
+	var failed []string
+	for _, status := range additionalRegistries {
+		if !status.Promoted {
+			failed = append(failed, status.Registry)
+		}
+	}
+	if len(failed) != 0 {
+		return results.ForReason("additional_registries").ForError(fmt.Errorf("could not promote to additional registries: %s", strings.Join(failed, ", ")))
 	}
 	return nil
 }
 
+// aliasTagDateFormat is the layout of the promotion time in date aliases
+const aliasTagDateFormat = "20060102-150405"
+
+// aliasSuffixes determines what is appended to the promoted tags for each of the aliases
+func aliasSuffixes(aliases []api.PromotionAlias, jobSpec *api.JobSpec, now time.Time) []string {
+	var suffixes []string
+	for _, alias := range aliases {
+		switch alias {
+		case api.PromotionAliasDate:
+			suffixes = append(suffixes, now.UTC().Format(aliasTagDateFormat))
+		case api.PromotionAliasCommit:
+			if jobSpec.Refs == nil || jobSpec.Refs.BaseSHA == "" {
+				logrus.Warn("Not tagging promoted images with the commit alias: the job does not identify the promoted commit.")
+				continue
+			}
+			suffixes = append(suffixes, jobSpec.Refs.BaseSHA)
+		}
+	}
+	return suffixes
+}
+
+// aliasTags returns the destinations of the alias with the suffix
+func aliasTags(tags map[string]api.ImageStreamTagReference, suffix string) map[string]api.ImageStreamTagReference {
+	aliased := make(map[string]api.ImageStreamTagReference, len(tags))
+	for src, dst := range tags {
+		dst.Tag = fmt.Sprintf("%s-%s", dst.Tag, suffix)
+		aliased[src] = dst
+	}
+	return aliased
+}
+
+// pruneAliases prunes the aliases of the promoted tags in every destination stream. The streams are
+// pruned through the client of the job, so the job must run on the cluster serving them. Pruning is
+// housekeeping, so a failure is reported but does not fail the promotion.
+func (s *promotionStep) pruneAliases(ctx context.Context, tags map[string]api.ImageStreamTagReference, pruning api.PruningConfiguration) {
+	streams := map[ctrlruntimeclient.ObjectKey]sets.String{}
+	for _, dst := range tags {
+		key := ctrlruntimeclient.ObjectKey{Namespace: dst.Namespace, Name: dst.Name}
+		if streams[key] == nil {
+			streams[key] = sets.NewString()
+		}
+		streams[key].Insert(dst.Tag)
+	}
+	for key, promoted := range streams {
+		pruned, err := PruneAliases(ctx, s.client, key, promoted, pruning, time.Now())
+		if len(pruned) != 0 {
+			logrus.Infof("Pruned aliases from imagestream %s: %s", key, strings.Join(pruned, ", "))
+		}
+		if err != nil {
+			logrus.WithError(err).Warnf("Could not prune aliases from imagestream %s.", key)
+		}
+	}
+}
+
+// destinationStreams fetches the image streams the tags are promoted to. Streams that
+// cannot be fetched are left out, so that their tags are mirrored as usual.
+func (s *promotionStep) destinationStreams(ctx context.Context, tags map[string]api.ImageStreamTagReference) map[ctrlruntimeclient.ObjectKey]*imagev1.ImageStream {
+	streams := map[ctrlruntimeclient.ObjectKey]*imagev1.ImageStream{}
+	for _, dst := range tags {
+		key := ctrlruntimeclient.ObjectKey{Namespace: dst.Namespace, Name: dst.Name}
+		if _, fetched := streams[key]; fetched {
+			continue
+		}
+		stream := &imagev1.ImageStream{}
+		if err := s.client.Get(ctx, key, stream); err != nil {
+			if !kerrors.IsNotFound(err) {
+				logrus.WithError(err).Debugf("Could not fetch destination imagestream %s.", key)
+			}
+			stream = nil
+		}
+		streams[key] = stream
+	}
+	return streams
+}
+
+// unchangedTags determines the tags whose destination already points to the image in
+// the pipeline, which do not need to be mirrored again
+func unchangedTags(tags map[string]api.ImageStreamTagReference, images *pipelineIndex, streams map[ctrlruntimeclient.ObjectKey]*imagev1.ImageStream) sets.String {
+	events := images.events
+	destinationEvents := map[ctrlruntimeclient.ObjectKey]map[string]imagev1.TagEvent{}
+	for key, stream := range streams {
+		if stream != nil {
+			destinationEvents[key] = latestTagEvents(stream)
+		}
+	}
+	unchanged := sets.NewString()
+	for src, dst := range tags {
+		image := events[src].Image
+		if image != "" && destinationEvents[ctrlruntimeclient.ObjectKey{Namespace: dst.Namespace, Name: dst.Name}][dst.Tag].Image == image {
+			unchanged.Insert(src)
+		}
+	}
+	return unchanged
+}
+
+// tagsExcept returns the tags without the given sources
+func tagsExcept(tags map[string]api.ImageStreamTagReference, excluded sets.String) map[string]api.ImageStreamTagReference {
+	remaining := map[string]api.ImageStreamTagReference{}
+	for src, dst := range tags {
+		if !excluded.Has(src) {
+			remaining[src] = dst
+		}
+	}
+	return remaining
+}
+
+// mirrorToAdditionalRegistries promotes the tags to every additional registry in a pod
+// of its own, so that a registry that is down or rejects the push does not prevent the
+// promotion to the others
+func (s *promotionStep) mirrorToAdditionalRegistries(ctx context.Context, tags map[string]api.ImageStreamTagReference, images *pipelineIndex) []RegistryStatus {
+	var statuses []RegistryStatus
+	for _, registry := range s.configuration.PromotionConfiguration.AdditionalRegistries {
+		status := RegistryStatus{Registry: registry}
+		imageMirrorTarget := getImageMirrorTarget(tags, images, registry)
+		if len(imageMirrorTarget) == 0 {
+			continue
+		}
+		if err := s.probe(ctx, registry); err != nil {
+			logrus.WithError(err).Warnf("Registry %s failed its health check, skipping promotion to it.", registry)
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+		if err := s.mirror(ctx, registryPodName(registry), imageMirrorTarget); err != nil {
+			logrus.WithError(err).Warnf("Could not promote to registry %s.", registry)
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+		status.Promoted = true
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// mirrorBackoff determines how often and how long apart failed mirror mappings are retried
+// unless the promotion configuration tunes it
+var mirrorBackoff = wait.Backoff{Duration: 30 * time.Second, Factor: 2, Steps: 3}
+
+// defaultMaxPerRegistry is the number of concurrent requests to a registry when mirroring
+const defaultMaxPerRegistry = 20
+
+func (s *promotionStep) mirrorConfiguration() api.MirrorConfiguration {
+	if s.configuration.PromotionConfiguration.Mirror == nil {
+		return api.MirrorConfiguration{}
+	}
+	return *s.configuration.PromotionConfiguration.Mirror
+}
+
+// mirrorBackoffFor applies the configured attempts and delay to the default backoff
+func mirrorBackoffFor(mirror api.MirrorConfiguration) wait.Backoff {
+	backoff := mirrorBackoff
+	if mirror.Attempts != 0 {
+		backoff.Steps = mirror.Attempts
+	}
+	if mirror.RetryDelay != nil {
+		backoff.Duration = mirror.RetryDelay.Duration
+	}
+	return backoff
+}
+
+// promotionPushSecrets returns the credentials to push with. Rehearsals push into the job
+// namespace, so they use the credentials of the namespace instead of the central ones.
+func (s *promotionStep) promotionPushSecrets(ctx context.Context, rehearsal bool) ([]*coreapi.Secret, error) {
+	if rehearsal {
+		secret, err := namespacePushSecret(ctx, s.client, s.jobSpec.Namespace())
+		if err != nil {
+			return nil, err
+		}
+		return []*coreapi.Secret{secret}, nil
+	}
+	pushSecrets, err := getPushSecrets(ctx, s.client, s.configuration.PromotionConfiguration.PushSecrets)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*coreapi.Secret{s.pushSecret}, pushSecrets...), nil
+}
+
+// promote publishes the images to their destinations, either through the API or in mirror pods
+func (s *promotionStep) promote(ctx context.Context, name string, imageMirrorTarget map[string]string) error {
+	if s.tagViaAPI {
+		return s.tagImages(ctx, imageMirrorTarget)
+	}
+	return s.mirror(ctx, name, imageMirrorTarget)
+}
+
+// mirror runs the mirror pods for the targets, one for every shard of the mappings
+func (s *promotionStep) mirror(ctx context.Context, name string, imageMirrorTarget map[string]string) error {
+	shards := shardMirrorTargets(imageMirrorTarget, s.mirrorConfiguration().ShardSize)
+	if len(shards) == 1 {
+		return s.mirrorShard(ctx, name, shards[0])
+	}
+	logrus.Infof("Mirroring %d images in %d parallel pods", len(imageMirrorTarget), len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard map[string]string) {
+			defer wg.Done()
+			errs[i] = s.mirrorShard(ctx, fmt.Sprintf("%s-%d", name, i), shard)
+		}(i, shard)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+// shardMirrorTargets splits the mappings into shards of at most size mappings each
+func shardMirrorTargets(imageMirrorTarget map[string]string, size int) []map[string]string {
+	if size <= 0 || len(imageMirrorTarget) <= size {
+		return []map[string]string{imageMirrorTarget}
+	}
+	sources := make([]string, 0, len(imageMirrorTarget))
+	for src := range imageMirrorTarget {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	var shards []map[string]string
+	for i, src := range sources {
+		if i%size == 0 {
+			shards = append(shards, map[string]string{})
+		}
+		shards[len(shards)-1][src] = imageMirrorTarget[src]
+	}
+	return shards
+}
+
+// mirrorShard runs a mirror pod for the targets. When some of the mappings fail, only those
+// are retried, and the error lists the destinations that could never be pushed.
+func (s *promotionStep) mirrorShard(ctx context.Context, name string, imageMirrorTarget map[string]string) error {
+	remaining := imageMirrorTarget
+	mirror := s.mirrorConfiguration()
+	backoff := mirrorBackoffFor(mirror)
+	metrics := MirrorMetrics{Pod: name, Images: len(imageMirrorTarget)}
+	start := time.Now()
+	defer func() {
+		metrics.DurationSeconds = time.Since(start).Seconds()
+		s.metrics.recordMirror(metrics)
+	}()
+	for {
+		metrics.Attempts++
+		pod := getPromotionPod(remaining, s.jobSpec.Namespace(), mirror)
+		pod.Name = name
+		result, err := s.runPod(ctx, pod)
+		if err == nil {
+			metrics.Failed = 0
+			return nil
+		}
+		if ctx.Err() != nil {
+			metrics.Interrupted = true
+			metrics.Pushed = pushedDestinations(imageMirrorTarget, remaining)
+			logrus.Warnf("Promotion was interrupted, %d of %d images were confirmed pushed by %s.", len(metrics.Pushed), len(imageMirrorTarget), name)
+			return fmt.Errorf("interrupted before pushing %s: %w", strings.Join(sortedDestinations(remaining), ", "), ctx.Err())
+		}
+		remaining = failedMirrorTargets(remaining, terminationMessage(result))
+		metrics.Failed = len(remaining)
+		if backoff.Steps <= 1 || ctx.Err() != nil {
+			return fmt.Errorf("could not push %s: %w", strings.Join(sortedDestinations(remaining), ", "), err)
+		}
+		delay := backoff.Step()
+		logrus.WithError(err).Warnf("Could not push %d images, retrying in %s.", len(remaining), delay)
+		select {
+		case <-ctx.Done():
+			metrics.Interrupted = true
+			metrics.Pushed = pushedDestinations(imageMirrorTarget, remaining)
+			return fmt.Errorf("could not push %s: %w", strings.Join(sortedDestinations(remaining), ", "), ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runPod runs a pod of the promotion, deleting it when the promotion is interrupted
+func (s *promotionStep) runPod(ctx context.Context, pod *coreapi.Pod) (*coreapi.Pod, error) {
+	result, err := steps.RunPod(ctx, s.client, pod)
+	if err != nil && ctx.Err() != nil {
+		s.deleteInterruptedPod(pod.Name)
+	}
+	return result, err
+}
+
+// deleteInterruptedPod removes a promotion pod whose promotion was interrupted, so that
+// it cannot push or modify images after the job has been reported as failed
+func (s *promotionStep) deleteInterruptedPod(name string) {
+	logrus.Infof("cleanup: Deleting promotion pod %s", name)
+	if err := s.client.Delete(context.Background(), &coreapi.Pod{ObjectMeta: meta.ObjectMeta{Namespace: s.jobSpec.Namespace(), Name: name}}); err != nil && !kerrors.IsNotFound(err) {
+		logrus.WithError(err).Warnf("Could not delete promotion pod %s.", name)
+	}
+}
+
+// pushedDestinations lists the destinations of the targets that are not among the remaining ones
+func pushedDestinations(imageMirrorTarget, remaining map[string]string) []string {
+	var pushed []string
+	for src, dst := range imageMirrorTarget {
+		if _, failed := remaining[src]; !failed {
+			pushed = append(pushed, dst)
+		}
+	}
+	sort.Strings(pushed)
+	return pushed
+}
+
+// terminationMessage returns the termination message of the first container of the pod
+func terminationMessage(pod *coreapi.Pod) string {
+	if pod == nil || len(pod.Status.ContainerStatuses) == 0 || pod.Status.ContainerStatuses[0].State.Terminated == nil {
+		return ""
+	}
+	return pod.Status.ContainerStatuses[0].State.Terminated.Message
+}
+
+// failedMirrorTargets picks the mappings whose destinations the promotion pod reported
+// as failed. When the report is missing, possibly truncated or unrecognized, every
+// mapping is considered failed so that none of them is lost.
+func failedMirrorTargets(imageMirrorTarget map[string]string, message string) map[string]string {
+	message = strings.TrimSpace(message)
+	if message == "" || len(message) >= maxTerminationMessageLength-1 {
+		return imageMirrorTarget
+	}
+	failedDestinations := sets.NewString(strings.Split(message, "\n")...)
+	failed := map[string]string{}
+	for src, dst := range imageMirrorTarget {
+		if failedDestinations.Has(dst) {
+			failed[src] = dst
+		}
+	}
+	if len(failed) == 0 {
+		return imageMirrorTarget
+	}
+	return failed
+}
+
+// sortedDestinations lists the destinations of the mappings
+func sortedDestinations(imageMirrorTarget map[string]string) []string {
+	destinations := make([]string, 0, len(imageMirrorTarget))
+	for _, dst := range imageMirrorTarget {
+		destinations = append(destinations, dst)
+	}
+	sort.Strings(destinations)
+	return destinations
+}
+
+// registryPodName returns the name of the pod promoting to an additional registry
+func registryPodName(registry string) string {
+	return "promotion-" + strings.NewReplacer(".", "-", ":", "-").Replace(strings.ToLower(registry))
+}
+
+// splitBuildCache separates the build cache from the component tags, as it is only
+// pushed on a best-effort basis
+func splitBuildCache(tags map[string]api.ImageStreamTagReference, buildCache api.ImageStreamTagReference) (map[string]api.ImageStreamTagReference, map[string]api.ImageStreamTagReference) {
+	components := map[string]api.ImageStreamTagReference{}
+	cache := map[string]api.ImageStreamTagReference{}
+	for src, dst := range tags {
+		if dst == buildCache {
+			cache[src] = dst
+			continue
+		}
+		components[src] = dst
+	}
+	return components, cache
+}
+
+// pushBuildCache mirrors the build cache in its own pod. A failure is reported but never
+// fails the promotion, since the components themselves were promoted fine.
+func (s *promotionStep) pushBuildCache(ctx context.Context, buildCacheMirrorTarget map[string]string) *BuildCacheStatus {
+	if len(buildCacheMirrorTarget) == 0 {
+		return nil
+	}
+	status := &BuildCacheStatus{}
+	for _, dst := range buildCacheMirrorTarget {
+		status.PullSpec = dst
+	}
+	if err := s.mirror(ctx, "promotion-build-cache", buildCacheMirrorTarget); err != nil {
+		logrus.WithError(err).Warnf("Could not push the build cache to %s, promoted components are not affected.", status.PullSpec)
+		status.Error = err.Error()
+		return status
+	}
+	status.Pushed = true
+	return status
+}
+
+// archiveImages mirrors the promoted images into the archive. Unlike the build cache,
+// the archive is kept for compliance, so failing to write to it fails the promotion.
+func (s *promotionStep) archiveImages(ctx context.Context, tags map[string]api.ImageStreamTagReference, images *pipelineIndex, imageMirrorTarget map[string]string, registry string, archive api.PromotionArchiveConfiguration) ([]ArchivedImage, error) {
+	archiveMirrorTarget, archived := getArchiveMirrorTarget(*s.configuration.PromotionConfiguration, archive, tags, images, registry, time.Now())
+	for src := range archiveMirrorTarget {
+		if _, promoted := imageMirrorTarget[src]; !promoted {
+			delete(archiveMirrorTarget, src)
+		}
+	}
+	if len(archiveMirrorTarget) == 0 {
+		return nil, nil
+	}
+	var kept []ArchivedImage
+	for _, image := range archived {
+		for _, dst := range archiveMirrorTarget {
+			if dst == image.PullSpec {
+				kept = append(kept, image)
+				break
+			}
+		}
+	}
+	logrus.Infof("Archiving %d promoted images to %s", len(archiveMirrorTarget), archive.Namespace)
+	if err := s.mirror(ctx, "promotion-archive", archiveMirrorTarget); err != nil {
+		return nil, fmt.Errorf("unable to archive promoted images: %w", err)
+	}
+	return kept, nil
+}
+
+// archiveTagDateFormat is the layout of the promotion date in archived tags
+const archiveTagDateFormat = "20060102"
+
+// getArchiveMirrorTarget maps the promoted images to their tags in the archive. Archived
+// tags carry the promotion date and the image digest, so an archived tag always points
+// to the same image and is never overwritten by a later promotion.
+func getArchiveMirrorTarget(config api.PromotionConfiguration, archive api.PromotionArchiveConfiguration, tags map[string]api.ImageStreamTagReference, images *pipelineIndex, registry string, now time.Time) (map[string]string, []ArchivedImage) {
+	if archive.Registry != "" {
+		registry = archive.Registry
+	}
+	now = now.UTC()
+	var retainUntil string
+	if archive.RetentionDays > 0 {
+		retainUntil = now.AddDate(0, 0, archive.RetentionDays).Format(time.RFC3339)
+	}
+	events := images.events
+	archiveMirror := map[string]string{}
+	var archived []ArchivedImage
+	for src, dst := range tags {
+		event := events[src]
+		if event.DockerImageReference == "" || !strings.HasPrefix(event.Image, "sha256:") {
+			continue
+		}
+		digest := strings.TrimPrefix(event.Image, "sha256:")
+		if len(digest) > 12 {
+			digest = digest[:12]
+		}
+		pullSpec := fmt.Sprintf("%s/%s/%s:%s-%s", registry, archive.Namespace, imageName(config, dst), now.Format(archiveTagDateFormat), digest)
+		archiveMirror[getPublicImageReference(event.DockerImageReference, images.publicDockerImageRepository)] = pullSpec
+		archived = append(archived, ArchivedImage{PullSpec: pullSpec, Digest: event.Image, RetainUntil: retainUntil})
+	}
+	sort.Slice(archived, func(i, j int) bool {
+		return archived[i].PullSpec < archived[j].PullSpec
+	})
+	return archiveMirror, archived
+}
+
+// maxTerminationMessageLength is the size the kubelet truncates termination messages to
+const maxTerminationMessageLength = 4096
+
+// changeBase determines the revision the promoted changes are based on. For pull requests
+// it is the base of the pull request. For pushes, prow links the compared range of the push,
+// whose start is the branch before the push, so every pushed commit is taken into account.
+// When neither identifies a base, an empty string is returned.
+func changeBase(refs *prowapi.Refs) string {
+	if refs == nil {
+		return ""
+	}
+	if len(refs.Pulls) != 0 {
+		return refs.BaseSHA
+	}
+	parts := strings.SplitN(refs.BaseLink, "/compare/", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	shas := strings.SplitN(parts[1], "...", 2)
+	if len(shas) != 2 || shas[0] == "" || strings.Trim(shas[0], "0") == "" {
+		// a push that created the branch compares against the null commit
+		return ""
+	}
+	return shas[0]
+}
+
+// onlyMatchingFilesChanged determines whether every file changed by the promoted commits
+// matches the pattern, by listing them from the checkout in the source image
+func (s *promotionStep) onlyMatchingFilesChanged(ctx context.Context, pattern string) (bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid skip_if_only_changed pattern: %w", err)
+	}
+	base := changeBase(s.jobSpec.Refs)
+	if base == "" {
+		return false, errors.New("the job does not identify the range of promoted commits")
+	}
+	pod, err := s.runPod(ctx, &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "promotion-changed-files",
+			Namespace: s.jobSpec.Namespace(),
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			Containers: []coreapi.Container{
+				{
+					Name:    "changed-files",
+					Image:   fmt.Sprintf("%s:%s", api.PipelineImageStream, api.PipelineImageStreamTagReferenceSource), // the cluster will resolve this relative ref for us when we create Pods with it
+					Command: []string{"/bin/sh", "-c", fmt.Sprintf("git diff --name-only %s HEAD > /dev/termination-log", shellQuote(base))},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to list changed files: %w", err)
+	}
+	if pod == nil || len(pod.Status.ContainerStatuses) == 0 || pod.Status.ContainerStatuses[0].State.Terminated == nil {
+		return false, errors.New("unable to list changed files: pod did not report them")
+	}
+	return allFilesMatch(pod.Status.ContainerStatuses[0].State.Terminated.Message, re), nil
+}
+
+// allFilesMatch determines whether every file in the newline-separated list matches.
+// An empty or possibly truncated list never matches, so we err on the side of promoting.
+func allFilesMatch(files string, re *regexp.Regexp) bool {
+	files = strings.TrimSpace(files)
+	if files == "" || len(files) >= maxTerminationMessageLength-1 {
+		return false
+	}
+	for _, file := range strings.Split(files, "\n") {
+		if !re.MatchString(file) {
+			return false
+		}
+	}
+	return true
+}
+
+// savePromotionManifest writes the promoted tags and their digests to an artifact
+// that release orchestration outside of CI can consume
+func savePromotionManifest(censor secretutil.Censorer, manifest PromotionManifest) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		logrus.WithError(err).Warn("Could not marshal the promotion manifest.")
+		return
+	}
+	if err := api.SaveArtifact(censor, PromotionManifestFilename, data); err != nil {
+		logrus.WithError(err).Warn("Could not save the promotion manifest.")
+	}
+}
+
+// experimentalDestinations collects the destinations that failed any of the configured gates
+func experimentalDestinations(eligibility api.PayloadEligibilityConfiguration, failedGates map[api.PayloadEligibilityGate]sets.String) sets.String {
+	experimental := sets.NewString()
+	for _, gate := range eligibility.Gates {
+		experimental = experimental.Union(failedGates[gate])
+	}
+	return experimental
+}
+
+// stampPayloadEligibility annotates the promoted tags in their destination image
+// streams with whether the release controller may include them in payloads
+func (s *promotionStep) stampPayloadEligibility(ctx context.Context, tags map[string]api.ImageStreamTagReference, registry string, imageMirrorTarget map[string]string, experimental sets.String) error {
+	promoted := sets.NewString()
+	for _, dst := range imageMirrorTarget {
+		promoted.Insert(dst)
+	}
+	byStream := map[ctrlruntimeclient.ObjectKey]map[string]api.PayloadEligibility{}
+	for _, dst := range tags {
+		destination := fmt.Sprintf("%s/%s", registry, dst.ISTagName())
+		if !promoted.Has(destination) {
+			continue
+		}
+		key := ctrlruntimeclient.ObjectKey{Namespace: dst.Namespace, Name: dst.Name}
+		if byStream[key] == nil {
+			byStream[key] = map[string]api.PayloadEligibility{}
+		}
+		byStream[key][dst.Tag] = api.PayloadEligibilityEligible
+		if experimental.Has(destination) {
+			byStream[key][dst.Tag] = api.PayloadEligibilityExperimental
+		}
+	}
+
+	for key, eligibilityByTag := range byStream {
+		var missing []string
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			stream := &imagev1.ImageStream{}
+			if err := s.client.Get(ctx, key, stream); err != nil {
+				return err
+			}
+			missing = setPayloadEligibility(stream, eligibilityByTag)
+			return s.client.Update(ctx, stream)
+		}); err != nil {
+			return fmt.Errorf("could not annotate payload eligibility in imagestream %s: %w", key, err)
+		}
+		if len(missing) != 0 {
+			logrus.Warnf("Could not annotate payload eligibility of tags %s in imagestream %s: they have no spec tag.", strings.Join(missing, ", "), key)
+		}
+	}
+	return nil
+}
+
+// imageName returns the name of the promoted image that is pushed to the destination
+func imageName(config api.PromotionConfiguration, dst api.ImageStreamTagReference) string {
+	if config.Name != "" {
+		return dst.Tag
+	}
+	return dst.Name
+}
+
+// setPayloadEligibility annotates the given tags in the image stream spec and
+// returns the tags that have no spec tag to annotate, e.g. ones that were only
+// pushed to the stream
+func setPayloadEligibility(stream *imagev1.ImageStream, eligibilityByTag map[string]api.PayloadEligibility) []string {
+	seen := sets.NewString()
+	for i, tag := range stream.Spec.Tags {
+		eligibility, promoted := eligibilityByTag[tag.Name]
+		if !promoted {
+			continue
+		}
+		if tag.Annotations == nil {
+			stream.Spec.Tags[i].Annotations = map[string]string{}
+		}
+		stream.Spec.Tags[i].Annotations[api.ReleaseAnnotationPayloadEligibility] = string(eligibility)
+		seen.Insert(tag.Name)
+	}
+	var missing []string
+	for tag := range eligibilityByTag {
+		if !seen.Has(tag) {
+			missing = append(missing, tag)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// attestProvenance attaches a SLSA provenance attestation to every promoted image
+func (s *promotionStep) attestProvenance(ctx context.Context, tags map[string]api.ImageStreamTagReference, images *pipelineIndex, registry string, provenance api.ProvenanceConfiguration) error {
+	subjects := attestationSubjects(tags, images, registry)
+	inputs, err := inputDependencies(s.inputSteps)
+	if err != nil {
+		return err
+	}
+	if err := copyProvenanceCredentials(ctx, s.client, s.jobSpec.Namespace(), provenance.Credentials); err != nil {
+		return err
+	}
+	pod, err := getProvenancePod(subjects, provenanceFor(s.jobSpec, inputs), provenance, s.jobSpec.Namespace())
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Attaching provenance attestations to %d promoted images", len(subjects))
+	if _, err := s.runPod(ctx, pod); err != nil {
+		return fmt.Errorf("unable to attach provenance attestations: %w", err)
+	}
+	return nil
+}
+
+// verifySignatures checks that the promoted images are signed by one of the
+// configured identities, failing or warning according to the policy. With the
+// warn policy, the destinations that failed the verification are returned.
+func (s *promotionStep) verifySignatures(ctx context.Context, imageMirrorTarget map[string]string, verification api.SignatureVerificationConfiguration) (sets.String, error) {
+	var destinations []string
+	for _, dst := range imageMirrorTarget {
+		destinations = append(destinations, dst)
+	}
+	logrus.Infof("Verifying signatures of %d promoted images", len(destinations))
+	pod, err := s.runPod(ctx, getVerificationPod(destinations, verification, s.jobSpec.Namespace()))
+	if err != nil {
+		if verification.Policy == api.VerificationPolicyWarn {
+			logrus.WithError(err).Warn("Promoted images failed signature verification.")
+			return unverifiedDestinations(destinations, terminationMessage(pod)), nil
+		}
+		return nil, fmt.Errorf("promoted images failed signature verification: %w", err)
+	}
+	return sets.NewString(), nil
+}
+
+// unverifiedDestinations picks the destinations the verification pod reported as
+// failed. When the report is missing, possibly truncated or unrecognized, every
+// destination is considered to have failed.
+func unverifiedDestinations(destinations []string, message string) sets.String {
+	message = strings.TrimSpace(message)
+	if message == "" || len(message) >= maxTerminationMessageLength-1 {
+		return sets.NewString(destinations...)
+	}
+	reported := sets.NewString(strings.Split(message, "\n")...)
+	if unknown := reported.Difference(sets.NewString(destinations...)); unknown.Len() != 0 {
+		return sets.NewString(destinations...)
+	}
+	return reported
+}
+
+// rehearsalJobPrefix is the prefix pj-rehearse gives to the names of rehearsal jobs
+const rehearsalJobPrefix = "rehearse-"
+
+// isRehearsal determines whether the job is a presubmit or a rehearsal, which must
+// never promote into the configured, production destinations
+func isRehearsal(jobSpec *api.JobSpec) bool {
+	return jobSpec.Type == prowapi.PresubmitJob || strings.HasPrefix(jobSpec.Job, rehearsalJobPrefix)
+}
+
+// rehearsalTags rewrites promotion destinations into the namespace of the job
+func rehearsalTags(tags map[string]api.ImageStreamTagReference, namespace string) map[string]api.ImageStreamTagReference {
+	rewritten := make(map[string]api.ImageStreamTagReference, len(tags))
+	for src, dst := range tags {
+		dst.Namespace = namespace
+		rewritten[src] = dst
+	}
+	return rewritten
+}
+
+// publicRegistryHost determines the public host of the registry serving the pipeline
+func publicRegistryHost(pipeline *imagev1.ImageStream) string {
+	splits := strings.Split(pipeline.Status.PublicDockerImageRepository, "/")
+	if len(splits) < 2 {
+		return ""
+	}
+	return splits[0]
+}
+
+// onJobCluster determines whether the registry is served by the cluster the job runs on,
+// in which case the destination image streams are accessible through the job client
+func onJobCluster(pipeline *imagev1.ImageStream, registry string) bool {
+	host := publicRegistryHost(pipeline)
+	return host != "" && host == registry
+}
+
 // registryDomain determines the domain of the registry we promote to
 func registryDomain(configuration *api.PromotionConfiguration) string {
 	registry := api.DomainForService(api.ServiceRegistry)
@@ -85,17 +1001,18 @@ func registryDomain(configuration *api.PromotionConfiguration) string {
 	return registry
 }
 
-func getImageMirrorTarget(tags map[string]api.ImageStreamTagReference, pipeline *imagev1.ImageStream, registry string) map[string]string {
-	if pipeline == nil {
+func getImageMirrorTarget(tags map[string]api.ImageStreamTagReference, images *pipelineIndex, registry string) map[string]string {
+	if images == nil {
 		return nil
 	}
+	events := images.events
 	imageMirror := map[string]string{}
 	for src, dst := range tags {
-		dockerImageReference := findDockerImageReference(pipeline, src)
+		dockerImageReference := events[src].DockerImageReference
 		if dockerImageReference == "" {
 			continue
 		}
-		dockerImageReference = getPublicImageReference(dockerImageReference, pipeline.Status.PublicDockerImageRepository)
+		dockerImageReference = getPublicImageReference(dockerImageReference, images.publicDockerImageRepository)
 		imageMirror[dockerImageReference] = fmt.Sprintf("%s/%s", registry, dst.ISTagName())
 	}
 	if len(imageMirror) == 0 {
@@ -124,7 +1041,7 @@ func getPublicImageReference(dockerImageReference, publicDockerImageRepository s
 	return strings.Replace(dockerImageReference, splits[0], publicHost, 1)
 }
 
-func getPromotionPod(imageMirrorTarget map[string]string, namespace string) *coreapi.Pod {
+func getPromotionPod(imageMirrorTarget map[string]string, namespace string, mirror api.MirrorConfiguration) *coreapi.Pod {
 	keys := make([]string, 0, len(imageMirrorTarget))
 	for k := range imageMirrorTarget {
 		keys = append(keys, k)
@@ -133,10 +1050,29 @@ func getPromotionPod(imageMirrorTarget map[string]string, namespace string) *cor
 
 	var images []string
 	for _, k := range keys {
-		images = append(images, fmt.Sprintf("%s=%s", k, imageMirrorTarget[k]))
+		images = append(images, shellQuote(fmt.Sprintf("%s=%s", k, imageMirrorTarget[k])))
+	}
+	// When mirroring everything at once fails, every mapping is retried on its own
+	// and the destinations that still fail are reported in the termination message.
+	registryConfig := filepath.Join(api.RegistryPushCredentialsCICentralSecretMountPath, coreapi.DockerConfigJsonKey)
+	maxPerRegistry := defaultMaxPerRegistry
+	if mirror.MaxPerRegistry != 0 {
+		maxPerRegistry = mirror.MaxPerRegistry
+	}
+	continueOnError := true
+	if mirror.ContinueOnError != nil {
+		continueOnError = *mirror.ContinueOnError
 	}
 	command := []string{"/bin/sh", "-c"}
-	args := []string{fmt.Sprintf("oc image mirror --registry-config=%s --continue-on-error=true --max-per-registry=20 %s", filepath.Join(api.RegistryPushCredentialsCICentralSecretMountPath, coreapi.DockerConfigJsonKey), strings.Join(images, " "))}
+	args := []string{fmt.Sprintf(`oc image mirror --registry-config=%[1]s --continue-on-error=%[3]t --max-per-registry=%[4]d %[2]s && exit 0
+failed=0
+for mapping in %[2]s; do
+  if ! oc image mirror --registry-config=%[1]s --max-per-registry=%[4]d "$mapping"; then
+    echo "${mapping#*=}" >> /dev/termination-log
+    failed=1
+  fi
+done
+exit $failed`, registryConfig, strings.Join(images, " "), continueOnError, maxPerRegistry)}
 	return &coreapi.Pod{
 		ObjectMeta: meta.ObjectMeta{
 			Name:      "promotion",
@@ -163,7 +1099,68 @@ func getPromotionPod(imageMirrorTarget map[string]string, namespace string) *cor
 				{
 					Name: "push-secret",
 					VolumeSource: coreapi.VolumeSource{
-						Secret: &coreapi.SecretVolumeSource{SecretName: api.RegistryPushCredentialsCICentralSecret},
+						Secret: &coreapi.SecretVolumeSource{SecretName: promotionRegistryConfigSecret},
+					},
+				},
+			},
+		},
+	}
+}
+
+// getVerificationPod returns a pod that runs cosign against every destination
+// and fails if any of them is not signed by one of the identities
+func getVerificationPod(destinations []string, verification api.SignatureVerificationConfiguration, namespace string) *coreapi.Pod {
+	sort.Strings(destinations)
+	var checks []string
+	for _, identity := range verification.Identities {
+		checks = append(checks, fmt.Sprintf("cosign verify --certificate-identity=%s --certificate-oidc-issuer=%s \"$1\" >/dev/null && return 0", shellQuote(identity.Identity), shellQuote(identity.Issuer)))
+	}
+	var images []string
+	for _, destination := range destinations {
+		images = append(images, shellQuote(destination))
+	}
+	script := fmt.Sprintf(`verify() {
+  %s
+  echo "$1 is not signed by any accepted identity"
+  echo "$1" >> /dev/termination-log
+  return 1
+}
+failed=0
+for image in %s; do
+  verify "$image" || failed=1
+done
+exit $failed`, strings.Join(checks, "\n  "), strings.Join(images, " "))
+	return &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "promotion-verification",
+			Namespace: namespace,
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			Containers: []coreapi.Container{
+				{
+					Name:    "verification",
+					Image:   promotionPodImage(verification.Image),
+					Command: []string{"/bin/sh", "-c"},
+					Args:    []string{script},
+					Env:     []coreapi.EnvVar{{Name: "DOCKER_CONFIG", Value: api.RegistryPushCredentialsCICentralSecretMountPath}},
+					VolumeMounts: []coreapi.VolumeMount{
+						{
+							Name:      "push-secret",
+							MountPath: api.RegistryPushCredentialsCICentralSecretMountPath,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Volumes: []coreapi.Volume{
+				{
+					Name: "push-secret",
+					VolumeSource: coreapi.VolumeSource{
+						Secret: &coreapi.SecretVolumeSource{
+							SecretName: promotionRegistryConfigSecret,
+							Items:      []coreapi.KeyToPath{{Key: coreapi.DockerConfigJsonKey, Path: "config.json"}},
+						},
 					},
 				},
 			},
@@ -171,19 +1168,85 @@ func getPromotionPod(imageMirrorTarget map[string]string, namespace string) *cor
 	}
 }
 
-// findDockerImageReference returns DockerImageReference, the string that can be used to pull this image,
-// to a tag if it exists in the ImageStream's Spec
-func findDockerImageReference(is *imagev1.ImageStream, tag string) string {
+// promotionPodImage is the pull spec of the configured image a promotion pod runs
+func promotionPodImage(image api.ImageStreamTagReference) string {
+	return fmt.Sprintf("%s/%s", api.DomainForService(api.ServiceRegistry), image.ISTagName())
+}
+
+// shellQuote quotes a value so it is passed verbatim as a single shell word
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// pipelineIndex holds the latest image of every tag of the pipeline. It is built once
+// per promotion and shared by every lookup, as the pipeline may have hundreds of tags.
+type pipelineIndex struct {
+	events                      map[string]imagev1.TagEvent
+	publicDockerImageRepository string
+}
+
+// indexPipeline builds the index of the pipeline
+func indexPipeline(pipeline *imagev1.ImageStream) *pipelineIndex {
+	if pipeline == nil {
+		return nil
+	}
+	return &pipelineIndex{
+		events:                      latestTagEvents(pipeline),
+		publicDockerImageRepository: pipeline.Status.PublicDockerImageRepository,
+	}
+}
+
+// latestTagEvents indexes the latest TagEvent for every tag in the ImageStream's status. The
+// DockerImageReference of an event is the string that can be used to pull its image. Building
+// the index once keeps lookups cheap when many tags are promoted out of a pipeline with
+// hundreds of tags.
+func latestTagEvents(is *imagev1.ImageStream) map[string]imagev1.TagEvent {
+	events := make(map[string]imagev1.TagEvent, len(is.Status.Tags))
 	for _, t := range is.Status.Tags {
-		if t.Tag != tag {
+		if _, seen := events[t.Tag]; seen || len(t.Items) == 0 {
 			continue
 		}
-		if len(t.Items) == 0 {
-			return ""
+		events[t.Tag] = t.Items[0]
+	}
+	return events
+}
+
+// withImagesFromOtherStreams resolves the additional images taken from image streams other
+// than the pipeline and adds them to a copy of the pipeline under their stream:tag source,
+// so they are promoted like any image from the pipeline
+func (s *promotionStep) withImagesFromOtherStreams(ctx context.Context, pipeline *imagev1.ImageStream, tags map[string]api.ImageStreamTagReference) (*imagev1.ImageStream, error) {
+	var resolved *imagev1.ImageStream
+	streams := map[string]map[string]imagev1.TagEvent{}
+	for _, src := range sets.StringKeySet(tags).List() {
+		name, tag := api.SplitAdditionalImageSource(src)
+		if name == api.PipelineImageStream {
+			continue
 		}
-		return t.Items[0].DockerImageReference
+		events, fetched := streams[name]
+		if !fetched {
+			stream := &imagev1.ImageStream{}
+			if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: s.jobSpec.Namespace(), Name: name}, stream); err != nil {
+				if !kerrors.IsNotFound(err) {
+					return nil, fmt.Errorf("could not resolve imagestream %s: %w", name, err)
+				}
+			} else {
+				events = latestTagEvents(stream)
+			}
+			streams[name] = events
+		}
+		event, ok := events[tag]
+		if !ok {
+			continue
+		}
+		if resolved == nil {
+			resolved = pipeline.DeepCopy()
+		}
+		resolved.Status.Tags = append(resolved.Status.Tags, imagev1.NamedTagEventList{Tag: src, Items: []imagev1.TagEvent{event}})
 	}
-	return ""
+	if resolved == nil {
+		return pipeline, nil
+	}
+	return resolved, nil
 }
 
 // toPromote determines the mapping of local tag to external tag which should be promoted
@@ -196,6 +1259,9 @@ func toPromote(config api.PromotionConfiguration, images []api.ProjectDirectoryI
 	}
 
 	for _, image := range images {
+		if image.Promote != nil && !*image.Promote {
+			continue
+		}
 		// if the image is required or non-optional, include it in promotion
 		tag := string(image.To)
 		if requiredImages.Has(tag) || !image.Optional {
@@ -203,9 +1269,16 @@ func toPromote(config api.PromotionConfiguration, images []api.ProjectDirectoryI
 			names.Insert(tag)
 		}
 	}
-	for _, tag := range config.ExcludedImages {
-		delete(tagsByDst, tag)
-		names.Delete(tag)
+	for _, pattern := range config.ExcludedImages {
+		for tag := range tagsByDst {
+			if excluded, err := api.MatchesExcludedImage(pattern, tag); err != nil {
+				logrus.WithError(err).Warnf("Ignoring invalid excluded image pattern %q.", pattern)
+				break
+			} else if excluded {
+				delete(tagsByDst, tag)
+				names.Delete(tag)
+			}
+		}
 	}
 	for dst, src := range config.AdditionalImages {
 		tagsByDst[dst] = src
@@ -285,12 +1358,15 @@ func (s *promotionStep) Objects() []ctrlruntimeclient.Object {
 
 // PromotionStep copies tags from the pipeline image stream to the destination defined in the promotion config.
 // If the source tag does not exist it is silently skipped.
-func PromotionStep(configuration *api.ReleaseBuildConfiguration, requiredImages sets.String, jobSpec *api.JobSpec, client steps.PodClient, pushSecret *coreapi.Secret) api.Step {
+func PromotionStep(configuration *api.ReleaseBuildConfiguration, requiredImages sets.String, jobSpec *api.JobSpec, client steps.PodClient, pullSecret, pushSecret *coreapi.Secret, inputSteps []api.Step, censor secretutil.Censorer) api.Step {
 	return &promotionStep{
 		configuration:  configuration,
 		requiredImages: requiredImages,
 		jobSpec:        jobSpec,
 		client:         client,
+		pullSecret:     pullSecret,
 		pushSecret:     pushSecret,
+		inputSteps:     inputSteps,
+		censor:         censor,
 	}
 }
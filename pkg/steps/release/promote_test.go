@@ -1,18 +1,38 @@
 package release
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
 	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	"k8s.io/test-infra/prow/secretutil"
 	"k8s.io/utils/diff"
+	utilpointer "k8s.io/utils/pointer"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	imageapi "github.com/openshift/api/image/v1"
 
 	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+	"github.com/openshift/ci-tools/pkg/steps"
+	"github.com/openshift/ci-tools/pkg/steps/loggingclient"
 	"github.com/openshift/ci-tools/pkg/testhelper"
 )
 
@@ -127,6 +147,48 @@ func TestToPromote(t *testing.T) {
 			expectedBySource: map[string]string{"bar": "bar", "baz": "baz", "boo": "ah"},
 			expectedNames:    sets.NewString("bar", "baz", "boo"),
 		},
+		{
+			name:   "images opted out of promotion are not promoted",
+			config: api.PromotionConfiguration{},
+			images: []api.ProjectDirectoryImageBuildStepConfiguration{
+				{To: api.PipelineImageStreamTagReference("foo"), Promote: utilpointer.BoolPtr(true)},
+				{To: api.PipelineImageStreamTagReference("bar"), Promote: utilpointer.BoolPtr(false)},
+				{To: api.PipelineImageStreamTagReference("baz"), Promote: utilpointer.BoolPtr(false), Optional: true},
+			},
+			requiredImages:   sets.NewString("baz"),
+			expectedBySource: map[string]string{"foo": "foo"},
+			expectedNames:    sets.NewString("foo"),
+		},
+		{
+			name: "glob and regular expression excludes filter families of images",
+			config: api.PromotionConfiguration{
+				ExcludedImages: []string{"tests-*", "/^bundle-[0-9]+$/"},
+			},
+			images: []api.ProjectDirectoryImageBuildStepConfiguration{
+				{To: api.PipelineImageStreamTagReference("foo")},
+				{To: api.PipelineImageStreamTagReference("tests-unit")},
+				{To: api.PipelineImageStreamTagReference("tests-e2e")},
+				{To: api.PipelineImageStreamTagReference("bundle-0")},
+				{To: api.PipelineImageStreamTagReference("bundle-tools")},
+			},
+			requiredImages:   sets.NewString(),
+			expectedBySource: map[string]string{"foo": "foo", "bundle-tools": "bundle-tools"},
+			expectedNames:    sets.NewString("foo", "bundle-tools"),
+		},
+		{
+			name: "additional images are promoted even when an exclude pattern matches them",
+			config: api.PromotionConfiguration{
+				ExcludedImages:   []string{"tests-*"},
+				AdditionalImages: map[string]string{"tests-extra": "foo"},
+			},
+			images: []api.ProjectDirectoryImageBuildStepConfiguration{
+				{To: api.PipelineImageStreamTagReference("foo")},
+				{To: api.PipelineImageStreamTagReference("tests-unit")},
+			},
+			requiredImages:   sets.NewString(),
+			expectedBySource: map[string]string{"foo": "foo", "tests-extra": "foo"},
+			expectedNames:    sets.NewString("foo", "tests-extra"),
+		},
 	}
 
 	for _, test := range testCases {
@@ -500,6 +562,7 @@ func TestGetPromotionPod(t *testing.T) {
 		name        string
 		imageMirror map[string]string
 		namespace   string
+		mirror      api.MirrorConfiguration
 		expected    *coreapi.Pod
 	}{
 		{
@@ -510,11 +573,132 @@ func TestGetPromotionPod(t *testing.T) {
 			},
 			namespace: "ci-op-zyvwvffx",
 		},
+		{
+			name: "tuned mirror",
+			imageMirror: map[string]string{
+				"docker-registry.default.svc:5000/ci-op-y2n8rsh3/pipeline@sha256:bbb": "registy.ci.openshift.org/ci/bin:latest",
+			},
+			namespace: "ci-op-zyvwvffx",
+			mirror:    api.MirrorConfiguration{MaxPerRegistry: 5, ContinueOnError: utilpointer.BoolPtr(false)},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testhelper.CompareWithFixture(t, getPromotionPod(testCase.imageMirror, testCase.namespace, testCase.mirror))
+		})
+	}
+}
+
+func TestGetVerificationPod(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		destinations []string
+		identities   []api.SignerIdentity
+		namespace    string
+	}{
+		{
+			name:         "single identity",
+			destinations: []string{"registry.ci.openshift.org/ci/bin:latest", "registry.ci.openshift.org/ci/applyconfig:latest"},
+			identities:   []api.SignerIdentity{{Identity: "release@openshift.io", Issuer: "https://accounts.google.com"}},
+			namespace:    "ci-op-zyvwvffx",
+		},
+		{
+			name:         "multiple identities",
+			destinations: []string{"registry.ci.openshift.org/ci/bin:latest"},
+			identities: []api.SignerIdentity{
+				{Identity: "release@openshift.io", Issuer: "https://accounts.google.com"},
+				{Identity: "it's-me@openshift.io", Issuer: "https://token.actions.githubusercontent.com"},
+			},
+			namespace: "ci-op-zyvwvffx",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			verification := api.SignatureVerificationConfiguration{
+				Image:      api.ImageStreamTagReference{Namespace: "ci", Name: "cosign", Tag: "v2"},
+				Identities: testCase.identities,
+			}
+			testhelper.CompareWithFixture(t, getVerificationPod(testCase.destinations, verification, testCase.namespace))
+		})
+	}
+}
+
+func TestSetPayloadEligibility(t *testing.T) {
+	var testCases = []struct {
+		name             string
+		stream           *imageapi.ImageStream
+		eligibilityByTag map[string]api.PayloadEligibility
+		expected         []imageapi.TagReference
+		expectedMissing  []string
+	}{
+		{
+			name: "spec tags are annotated, others are untouched",
+			stream: &imageapi.ImageStream{Spec: imageapi.ImageStreamSpec{Tags: []imageapi.TagReference{
+				{Name: "a", From: &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "stream@sha256:a"}},
+				{Name: "b", Annotations: map[string]string{"other": "value", "release.openshift.io/payload-eligibility": "eligible"}},
+				{Name: "unrelated"},
+			}}},
+			eligibilityByTag: map[string]api.PayloadEligibility{"a": api.PayloadEligibilityEligible, "b": api.PayloadEligibilityExperimental},
+			expected: []imageapi.TagReference{
+				{Name: "a", From: &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "stream@sha256:a"}, Annotations: map[string]string{"release.openshift.io/payload-eligibility": "eligible"}},
+				{Name: "b", Annotations: map[string]string{"other": "value", "release.openshift.io/payload-eligibility": "experimental"}},
+				{Name: "unrelated"},
+			},
+		},
+		{
+			name:             "tags only pushed to the stream are reported and not added to the spec",
+			stream:           &imageapi.ImageStream{Spec: imageapi.ImageStreamSpec{Tags: []imageapi.TagReference{{Name: "a"}}}},
+			eligibilityByTag: map[string]api.PayloadEligibility{"c": api.PayloadEligibilityEligible, "a": api.PayloadEligibilityEligible, "b": api.PayloadEligibilityExperimental},
+			expected: []imageapi.TagReference{
+				{Name: "a", Annotations: map[string]string{"release.openshift.io/payload-eligibility": "eligible"}},
+			},
+			expectedMissing: []string{"b", "c"},
+		},
 	}
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			testhelper.CompareWithFixture(t, getPromotionPod(testCase.imageMirror, testCase.namespace))
+			missing := setPayloadEligibility(testCase.stream, testCase.eligibilityByTag)
+			if diff := cmp.Diff(testCase.expected, testCase.stream.Spec.Tags); diff != "" {
+				t.Errorf("%s: got incorrect spec tags: %v", testCase.name, diff)
+			}
+			if diff := cmp.Diff(testCase.expectedMissing, missing); diff != "" {
+				t.Errorf("%s: got incorrect missing tags: %v", testCase.name, diff)
+			}
+		})
+	}
+}
+
+func TestUnverifiedDestinations(t *testing.T) {
+	destinations := []string{"registry.ci.openshift.org/ci/a:latest", "registry.ci.openshift.org/ci/b:latest"}
+	var testCases = []struct {
+		name     string
+		message  string
+		expected sets.String
+	}{
+		{
+			name:     "reported destinations failed",
+			message:  "registry.ci.openshift.org/ci/b:latest\n",
+			expected: sets.NewString("registry.ci.openshift.org/ci/b:latest"),
+		},
+		{
+			name:     "missing report fails every destination",
+			expected: sets.NewString(destinations...),
+		},
+		{
+			name:     "unrecognized report fails every destination",
+			message:  "cosign: command not found",
+			expected: sets.NewString(destinations...),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, unverifiedDestinations(destinations, testCase.message)); diff != "" {
+				t.Errorf("%s: got incorrect destinations: %v", testCase.name, diff)
+			}
 		})
 	}
 }
@@ -578,7 +762,7 @@ func TestGetImageMirror(t *testing.T) {
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			if actual, expected := getImageMirrorTarget(testCase.tags, testCase.pipeline, "registry.ci.openshift.org"), testCase.expected; !reflect.DeepEqual(actual, expected) {
+			if actual, expected := getImageMirrorTarget(testCase.tags, indexPipeline(testCase.pipeline), "registry.ci.openshift.org"), testCase.expected; !reflect.DeepEqual(actual, expected) {
 				t.Errorf("%s: got incorrect ImageMirror mapping: %v", testCase.name, diff.ObjectDiff(actual, expected))
 			}
 		})
@@ -634,3 +818,950 @@ func TestGetPublicImageReference(t *testing.T) {
 		})
 	}
 }
+
+func TestLatestTagEvents(t *testing.T) {
+	stream := &imageapi.ImageStream{
+		Status: imageapi.ImageStreamStatus{
+			Tags: []imageapi.NamedTagEventList{
+				{Tag: "a", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:aaa"}, {DockerImageReference: "registry/ns/pipeline@sha256:old"}}},
+				{Tag: "b"},
+				{Tag: "c", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:ccc"}}},
+			},
+		},
+	}
+	expected := map[string]imageapi.TagEvent{
+		"a": {DockerImageReference: "registry/ns/pipeline@sha256:aaa"},
+		"c": {DockerImageReference: "registry/ns/pipeline@sha256:ccc"},
+	}
+	if diff := cmp.Diff(expected, latestTagEvents(stream)); diff != "" {
+		t.Errorf("got incorrect tag events: %v", diff)
+	}
+}
+
+func TestAllFilesMatch(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		files    string
+		expected bool
+	}{
+		{
+			name:     "only docs changed",
+			files:    "docs/README.md\nCHANGELOG.md\n",
+			expected: true,
+		},
+		{
+			name:  "code changed",
+			files: "docs/README.md\nmain.go\n",
+		},
+		{
+			name: "nothing changed",
+		},
+		{
+			name:  "possibly truncated list",
+			files: strings.Repeat("docs/README.md\n", 300),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := allFilesMatch(testCase.files, regexp.MustCompile(`^docs/|\.md$`)); actual != testCase.expected {
+				t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsRehearsal(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		jobSpec  *api.JobSpec
+		expected bool
+	}{
+		{
+			name:    "postsubmit",
+			jobSpec: &api.JobSpec{JobSpec: downwardapi.JobSpec{Type: prowapi.PostsubmitJob, Job: "branch-ci-org-repo-master-images"}},
+		},
+		{
+			name:     "presubmit",
+			jobSpec:  &api.JobSpec{JobSpec: downwardapi.JobSpec{Type: prowapi.PresubmitJob, Job: "pull-ci-org-repo-master-images"}},
+			expected: true,
+		},
+		{
+			name:     "rehearsed periodic",
+			jobSpec:  &api.JobSpec{JobSpec: downwardapi.JobSpec{Type: prowapi.PeriodicJob, Job: "rehearse-1234-periodic-ci-org-repo-master-images"}},
+			expected: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := isRehearsal(testCase.jobSpec); actual != testCase.expected {
+				t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRehearsalTags(t *testing.T) {
+	tags := map[string]api.ImageStreamTagReference{
+		"foo": {Namespace: "ocp", Name: "4.8", Tag: "foo"},
+		"bin": {Namespace: "build-cache", Name: "org-repo", Tag: "master"},
+	}
+	expected := map[string]api.ImageStreamTagReference{
+		"foo": {Namespace: "ci-op-zyvwvffx", Name: "4.8", Tag: "foo"},
+		"bin": {Namespace: "ci-op-zyvwvffx", Name: "org-repo", Tag: "master"},
+	}
+	if diff := cmp.Diff(expected, rehearsalTags(tags, "ci-op-zyvwvffx")); diff != "" {
+		t.Errorf("got incorrect rehearsal tags: %v", diff)
+	}
+	if tags["foo"].Namespace != "ocp" {
+		t.Error("rehearsalTags modified its input")
+	}
+}
+
+func TestPublicRegistryHost(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		repository string
+		expected   string
+	}{
+		{
+			name:       "public repository",
+			repository: "registry.build01.ci.openshift.org/ci-op-zyvwvffx/pipeline",
+			expected:   "registry.build01.ci.openshift.org",
+		},
+		{
+			name: "no public repository",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			pipeline := &imageapi.ImageStream{Status: imageapi.ImageStreamStatus{PublicDockerImageRepository: testCase.repository}}
+			if diff := cmp.Diff(testCase.expected, publicRegistryHost(pipeline)); diff != "" {
+				t.Errorf("%s: got incorrect registry host: %v", testCase.name, diff)
+			}
+		})
+	}
+}
+
+func TestSplitBuildCache(t *testing.T) {
+	buildCache := api.ImageStreamTagReference{Namespace: "build-cache", Name: "org-repo", Tag: "master"}
+	tags := map[string]api.ImageStreamTagReference{
+		"foo": {Namespace: "ocp", Name: "4.8", Tag: "foo"},
+		"bin": buildCache,
+	}
+	components, cache := splitBuildCache(tags, buildCache)
+	if diff := cmp.Diff(map[string]api.ImageStreamTagReference{"foo": {Namespace: "ocp", Name: "4.8", Tag: "foo"}}, components); diff != "" {
+		t.Errorf("got incorrect component tags: %v", diff)
+	}
+	if diff := cmp.Diff(map[string]api.ImageStreamTagReference{"bin": buildCache}, cache); diff != "" {
+		t.Errorf("got incorrect build cache tags: %v", diff)
+	}
+}
+
+func TestGetArchiveMirrorTarget(t *testing.T) {
+	pipeline := &imageapi.ImageStream{
+		Status: imageapi.ImageStreamStatus{
+			Tags: []imageapi.NamedTagEventList{
+				{Tag: "foo", Items: []imageapi.TagEvent{{DockerImageReference: "registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:0123456789abcdef", Image: "sha256:0123456789abcdef"}}},
+				{Tag: "bar", Items: []imageapi.TagEvent{{DockerImageReference: "registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:fedcba9876543210", Image: "sha256:fedcba9876543210"}}},
+			},
+		},
+	}
+	tags := map[string]api.ImageStreamTagReference{
+		"foo":     {Namespace: "ocp", Name: "4.8", Tag: "foo"},
+		"bar":     {Namespace: "ocp", Name: "4.8", Tag: "bar"},
+		"missing": {Namespace: "ocp", Name: "4.8", Tag: "missing"},
+	}
+	now := time.Date(2021, time.March, 4, 23, 0, 0, 0, time.FixedZone("", -2*60*60))
+	config := api.PromotionConfiguration{Namespace: "ocp", Name: "4.8"}
+
+	var testCases = []struct {
+		name             string
+		archive          api.PromotionArchiveConfiguration
+		expectedTarget   map[string]string
+		expectedArchived []ArchivedImage
+	}{
+		{
+			name:    "archive in the promotion registry without retention",
+			archive: api.PromotionArchiveConfiguration{Namespace: "archive"},
+			expectedTarget: map[string]string{
+				"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:0123456789abcdef": "quay.io/archive/foo:20210305-0123456789ab",
+				"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:fedcba9876543210": "quay.io/archive/bar:20210305-fedcba987654",
+			},
+			expectedArchived: []ArchivedImage{
+				{PullSpec: "quay.io/archive/bar:20210305-fedcba987654", Digest: "sha256:fedcba9876543210"},
+				{PullSpec: "quay.io/archive/foo:20210305-0123456789ab", Digest: "sha256:0123456789abcdef"},
+			},
+		},
+		{
+			name:    "archive in its own registry with retention",
+			archive: api.PromotionArchiveConfiguration{Registry: "archive.example.com", Namespace: "ocp", RetentionDays: 30},
+			expectedTarget: map[string]string{
+				"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:0123456789abcdef": "archive.example.com/ocp/foo:20210305-0123456789ab",
+				"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:fedcba9876543210": "archive.example.com/ocp/bar:20210305-fedcba987654",
+			},
+			expectedArchived: []ArchivedImage{
+				{PullSpec: "archive.example.com/ocp/bar:20210305-fedcba987654", Digest: "sha256:fedcba9876543210", RetainUntil: "2021-04-04T01:00:00Z"},
+				{PullSpec: "archive.example.com/ocp/foo:20210305-0123456789ab", Digest: "sha256:0123456789abcdef", RetainUntil: "2021-04-04T01:00:00Z"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			target, archived := getArchiveMirrorTarget(config, testCase.archive, tags, indexPipeline(pipeline), "quay.io", now)
+			if diff := cmp.Diff(testCase.expectedTarget, target); diff != "" {
+				t.Errorf("got incorrect archive mirror target: %v", diff)
+			}
+			if diff := cmp.Diff(testCase.expectedArchived, archived); diff != "" {
+				t.Errorf("got incorrect archived images: %v", diff)
+			}
+		})
+	}
+}
+
+func TestRegistryPodName(t *testing.T) {
+	var testCases = []struct {
+		registry string
+		expected string
+	}{
+		{registry: "quay.io", expected: "promotion-quay-io"},
+		{registry: "Registry.example.com:5000", expected: "promotion-registry-example-com-5000"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.registry, func(t *testing.T) {
+			if actual := registryPodName(testCase.registry); actual != testCase.expected {
+				t.Errorf("expected pod name %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUnchangedTags(t *testing.T) {
+	pipeline := &imageapi.ImageStream{
+		Status: imageapi.ImageStreamStatus{
+			Tags: []imageapi.NamedTagEventList{
+				{Tag: "same", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:aaa", Image: "sha256:aaa"}}},
+				{Tag: "changed", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:bbb", Image: "sha256:bbb"}}},
+				{Tag: "new", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:ccc", Image: "sha256:ccc"}}},
+				{Tag: "elsewhere", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:ddd", Image: "sha256:ddd"}}},
+			},
+		},
+	}
+	tags := map[string]api.ImageStreamTagReference{
+		"same":      {Namespace: "ocp", Name: "4.8", Tag: "same"},
+		"changed":   {Namespace: "ocp", Name: "4.8", Tag: "changed"},
+		"new":       {Namespace: "ocp", Name: "4.8", Tag: "new"},
+		"elsewhere": {Namespace: "ocp", Name: "missing", Tag: "elsewhere"},
+	}
+	streams := map[ctrlruntimeclient.ObjectKey]*imageapi.ImageStream{
+		{Namespace: "ocp", Name: "4.8"}: {
+			Status: imageapi.ImageStreamStatus{
+				Tags: []imageapi.NamedTagEventList{
+					{Tag: "same", Items: []imageapi.TagEvent{{Image: "sha256:aaa"}}},
+					{Tag: "changed", Items: []imageapi.TagEvent{{Image: "sha256:000"}, {Image: "sha256:bbb"}}},
+				},
+			},
+		},
+		{Namespace: "ocp", Name: "missing"}: nil,
+	}
+	unchanged := unchangedTags(tags, indexPipeline(pipeline), streams)
+	if diff := cmp.Diff([]string{"same"}, unchanged.List()); diff != "" {
+		t.Errorf("got incorrect unchanged tags: %v", diff)
+	}
+	if diff := cmp.Diff(map[string]api.ImageStreamTagReference{
+		"changed":   {Namespace: "ocp", Name: "4.8", Tag: "changed"},
+		"new":       {Namespace: "ocp", Name: "4.8", Tag: "new"},
+		"elsewhere": {Namespace: "ocp", Name: "missing", Tag: "elsewhere"},
+	}, tagsExcept(tags, unchanged)); diff != "" {
+		t.Errorf("got incorrect remaining tags: %v", diff)
+	}
+}
+
+func TestFailedMirrorTargets(t *testing.T) {
+	targets := map[string]string{
+		"registry/ns/pipeline@sha256:aaa": "quay.io/ocp/4.8:a",
+		"registry/ns/pipeline@sha256:bbb": "quay.io/ocp/4.8:b",
+		"registry/ns/pipeline@sha256:ccc": "quay.io/ocp/4.8:c",
+	}
+	var testCases = []struct {
+		name     string
+		message  string
+		expected map[string]string
+	}{
+		{
+			name:     "no report retries everything",
+			expected: targets,
+		},
+		{
+			name:    "reported destinations are retried",
+			message: "quay.io/ocp/4.8:a\nquay.io/ocp/4.8:c\n",
+			expected: map[string]string{
+				"registry/ns/pipeline@sha256:aaa": "quay.io/ocp/4.8:a",
+				"registry/ns/pipeline@sha256:ccc": "quay.io/ocp/4.8:c",
+			},
+		},
+		{
+			name:     "unrecognized report retries everything",
+			message:  "error: something went wrong",
+			expected: targets,
+		},
+		{
+			name:     "truncated report retries everything",
+			message:  "quay.io/ocp/4.8:a\n" + strings.Repeat("x", maxTerminationMessageLength),
+			expected: targets,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, failedMirrorTargets(targets, testCase.message)); diff != "" {
+				t.Errorf("got incorrect failed targets: %v", diff)
+			}
+		})
+	}
+}
+
+func TestShardMirrorTargets(t *testing.T) {
+	targets := map[string]string{
+		"registry/ns/pipeline@sha256:aaa": "quay.io/ocp/4.8:a",
+		"registry/ns/pipeline@sha256:bbb": "quay.io/ocp/4.8:b",
+		"registry/ns/pipeline@sha256:ccc": "quay.io/ocp/4.8:c",
+	}
+	var testCases = []struct {
+		name     string
+		size     int
+		expected []map[string]string
+	}{
+		{
+			name:     "no shard size mirrors in a single pod",
+			expected: []map[string]string{targets},
+		},
+		{
+			name:     "shard size above the number of images mirrors in a single pod",
+			size:     5,
+			expected: []map[string]string{targets},
+		},
+		{
+			name: "images are split into shards",
+			size: 2,
+			expected: []map[string]string{
+				{
+					"registry/ns/pipeline@sha256:aaa": "quay.io/ocp/4.8:a",
+					"registry/ns/pipeline@sha256:bbb": "quay.io/ocp/4.8:b",
+				},
+				{
+					"registry/ns/pipeline@sha256:ccc": "quay.io/ocp/4.8:c",
+				},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, shardMirrorTargets(targets, testCase.size)); diff != "" {
+				t.Errorf("got incorrect shards: %v", diff)
+			}
+		})
+	}
+}
+
+func TestMirrorBackoffFor(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		mirror   api.MirrorConfiguration
+		expected wait.Backoff
+	}{
+		{
+			name:     "defaults",
+			expected: mirrorBackoff,
+		},
+		{
+			name:     "tuned attempts",
+			mirror:   api.MirrorConfiguration{Attempts: 1, RetryDelay: &prowapi.Duration{Duration: time.Minute}},
+			expected: wait.Backoff{Duration: time.Minute, Factor: 2, Steps: 1},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, mirrorBackoffFor(testCase.mirror)); diff != "" {
+				t.Errorf("got incorrect backoff: %v", diff)
+			}
+		})
+	}
+}
+
+func TestAliasSuffixes(t *testing.T) {
+	now := time.Date(2021, time.March, 4, 13, 4, 5, 0, time.FixedZone("", 2*60*60))
+	var testCases = []struct {
+		name     string
+		aliases  []api.PromotionAlias
+		refs     *prowapi.Refs
+		expected []string
+	}{
+		{
+			name: "no aliases",
+			refs: &prowapi.Refs{BaseSHA: "abcdef"},
+		},
+		{
+			name:     "date and commit",
+			aliases:  []api.PromotionAlias{api.PromotionAliasDate, api.PromotionAliasCommit},
+			refs:     &prowapi.Refs{BaseSHA: "abcdef"},
+			expected: []string{"20210304-110405", "abcdef"},
+		},
+		{
+			name:     "commit alias without refs is skipped",
+			aliases:  []api.PromotionAlias{api.PromotionAliasCommit, api.PromotionAliasDate},
+			expected: []string{"20210304-110405"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{Refs: testCase.refs}}
+			if diff := cmp.Diff(testCase.expected, aliasSuffixes(testCase.aliases, jobSpec, now)); diff != "" {
+				t.Errorf("got incorrect suffixes: %v", diff)
+			}
+		})
+	}
+}
+
+func TestAliasTags(t *testing.T) {
+	tags := map[string]api.ImageStreamTagReference{
+		"foo": {Namespace: "ocp", Name: "4.8", Tag: "foo"},
+	}
+	expected := map[string]api.ImageStreamTagReference{
+		"foo": {Namespace: "ocp", Name: "4.8", Tag: "foo-abcdef"},
+	}
+	if diff := cmp.Diff(expected, aliasTags(tags, "abcdef")); diff != "" {
+		t.Errorf("got incorrect alias tags: %v", diff)
+	}
+	if tags["foo"].Tag != "foo" {
+		t.Errorf("aliasTags modified its input")
+	}
+}
+
+// blockingClient never answers reads before the context is done
+type blockingClient struct {
+	ctrlruntimeclient.WithWatch
+}
+
+func (c blockingClient) Get(ctx context.Context, key ctrlruntimeclient.ObjectKey, obj ctrlruntimeclient.Object) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunTimeout(t *testing.T) {
+	s := &promotionStep{
+		configuration: &api.ReleaseBuildConfiguration{
+			Images: []api.ProjectDirectoryImageBuildStepConfiguration{{To: "foo"}},
+			PromotionConfiguration: &api.PromotionConfiguration{
+				Namespace: "ocp",
+				Name:      "4.8",
+				Timeout:   &prowapi.Duration{Duration: 10 * time.Millisecond},
+			},
+		},
+		jobSpec: &api.JobSpec{},
+		client:  steps.NewPodClient(loggingclient.New(blockingClient{WithWatch: fakectrlruntimeclient.NewFakeClient()}), nil, nil),
+	}
+	err := s.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected the promotion to time out, got no error")
+	}
+	if diff := cmp.Diff([]string{"promoting_images:promotion_timeout:resolving_pipeline"}, results.Reasons(err)); diff != "" {
+		t.Errorf("got incorrect reasons: %s", diff)
+	}
+}
+
+// fakePodRunner completes every pod it creates, failing the ones named in failures,
+// and records the pods in the order they were created
+type fakePodRunner struct {
+	ctrlruntimeclient.WithWatch
+	failures            sets.String
+	terminationMessages map[string]string
+
+	lock sync.Mutex
+	pods []string
+}
+
+func (c *fakePodRunner) Create(ctx context.Context, obj ctrlruntimeclient.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	if pod, ok := obj.(*coreapi.Pod); ok {
+		c.lock.Lock()
+		c.pods = append(c.pods, promotionPodSummary(pod))
+		c.lock.Unlock()
+	}
+	return c.WithWatch.Create(ctx, obj, opts...)
+}
+
+func (c *fakePodRunner) Get(ctx context.Context, key ctrlruntimeclient.ObjectKey, obj ctrlruntimeclient.Object) error {
+	if err := c.WithWatch.Get(ctx, key, obj); err != nil {
+		return err
+	}
+	if pod, ok := obj.(*coreapi.Pod); ok {
+		pod.Status.Phase = coreapi.PodSucceeded
+		terminated := &coreapi.ContainerStateTerminated{Message: c.terminationMessages[key.Name]}
+		if c.failures.Has(key.Name) {
+			pod.Status.Phase = coreapi.PodFailed
+			terminated.ExitCode = 1
+		}
+		pod.Status.ContainerStatuses = nil
+		for _, container := range pod.Spec.Containers {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, coreapi.ContainerStatus{
+				Name:  container.Name,
+				State: coreapi.ContainerState{Terminated: terminated},
+			})
+		}
+	}
+	return nil
+}
+
+// noLogsPodClient has no logs for the failed pods
+type noLogsPodClient struct {
+	steps.PodClient
+}
+
+func (noLogsPodClient) GetLogs(string, string, *coreapi.PodLogOptions) *rest.Request {
+	return rest.NewRequestWithClient(nil, "", rest.ClientContentConfig{}, nil)
+}
+
+// mirrorMapping matches the quoted src=dst mappings of a mirror pod
+var mirrorMapping = regexp.MustCompile(`'[^'=]+=([^']+)'`)
+
+// promotionPodSummary names the pod and, for mirror pods, the destinations pushed to
+func promotionPodSummary(pod *coreapi.Pod) string {
+	if len(pod.Spec.Containers) == 0 || pod.Spec.Containers[0].Name != "promotion" {
+		return pod.Name
+	}
+	command := strings.SplitN(pod.Spec.Containers[0].Args[0], "\n", 2)[0]
+	var destinations []string
+	for _, match := range mirrorMapping.FindAllStringSubmatch(command, -1) {
+		destinations = append(destinations, match[1])
+	}
+	sort.Strings(destinations)
+	return fmt.Sprintf("%s: %s", pod.Name, strings.Join(destinations, ", "))
+}
+
+func TestRun(t *testing.T) {
+	pipelineAt := func(publicRepository string) *imageapi.ImageStream {
+		return &imageapi.ImageStream{
+			ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1", Name: api.PipelineImageStream},
+			Status: imageapi.ImageStreamStatus{
+				PublicDockerImageRepository: publicRepository + "/ci-op-1/pipeline",
+				Tags: []imageapi.NamedTagEventList{
+					{Tag: "bar", Items: []imageapi.TagEvent{{DockerImageReference: publicRepository + "/ci-op-1/pipeline@sha256:bar", Image: "sha256:bar"}}},
+					{Tag: "foo", Items: []imageapi.TagEvent{{DockerImageReference: publicRepository + "/ci-op-1/pipeline@sha256:foo", Image: "sha256:foo"}}},
+				},
+			},
+		}
+	}
+	// the destination stream already holds the image of foo that the pipeline holds
+	destination := &imageapi.ImageStream{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8"},
+		Status: imageapi.ImageStreamStatus{Tags: []imageapi.NamedTagEventList{
+			{Tag: "foo", Items: []imageapi.TagEvent{{DockerImageReference: "registry.ci.openshift.org/ocp/4.8@sha256:foo", Image: "sha256:foo"}}},
+		}},
+	}
+	// the older alias of bar is pruned when only the newest one is kept
+	aliased := &imageapi.ImageStream{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8"},
+		Status: imageapi.ImageStreamStatus{Tags: []imageapi.NamedTagEventList{
+			{Tag: "bar-20200101-000000", Items: []imageapi.TagEvent{{Image: "sha256:old", Created: meta.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}}},
+			{Tag: "bar-20200102-000000", Items: []imageapi.TagEvent{{Image: "sha256:new", Created: meta.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}}},
+		}},
+	}
+	aliases := []ctrlruntimeclient.Object{
+		aliased,
+		&imageapi.ImageStreamTag{ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8:bar-20200101-000000"}},
+		&imageapi.ImageStreamTag{ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8:bar-20200102-000000"}},
+	}
+	aliasesLeft := func(expected ...string) func(*testing.T, ctrlruntimeclient.Client) {
+		return func(t *testing.T, client ctrlruntimeclient.Client) {
+			istags := &imageapi.ImageStreamTagList{}
+			if err := client.List(context.Background(), istags, ctrlruntimeclient.InNamespace("ocp")); err != nil {
+				t.Fatalf("could not list imagestreamtags: %v", err)
+			}
+			var actual []string
+			for _, istag := range istags.Items {
+				actual = append(actual, istag.Name)
+			}
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Errorf("got incorrect aliases: %s", diff)
+			}
+		}
+	}
+	verification := func(policy api.VerificationPolicy) *api.SignatureVerificationConfiguration {
+		return &api.SignatureVerificationConfiguration{
+			Image:      api.ImageStreamTagReference{Namespace: "ci", Name: "cosign", Tag: "v2"},
+			Identities: []api.SignerIdentity{{Identity: "https://github.com/org/repo", Issuer: "https://token.actions.githubusercontent.com"}},
+			Policy:     policy,
+		}
+	}
+	const baseSHA = "0123456789abcdef0123456789abcdef01234567"
+	// foo was reverted to an older image, as was a tag that is not promoted
+	reverted := &imageapi.ImageStream{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8"},
+		Spec: imageapi.ImageStreamSpec{Tags: []imageapi.TagReference{
+			{
+				Name:        "foo",
+				From:        &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "4.8@sha256:old"},
+				Annotations: map[string]string{api.ReleaseAnnotationRevertReason: "broke the installer"},
+			},
+			{
+				Name:        "other",
+				From:        &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "4.8@sha256:old"},
+				Annotations: map[string]string{api.ReleaseAnnotationRevertReason: "broke the installer"},
+			},
+		}},
+		Status: imageapi.ImageStreamStatus{Tags: []imageapi.NamedTagEventList{
+			{Tag: "foo", Items: []imageapi.TagEvent{{DockerImageReference: "registry.ci.openshift.org/ocp/4.8@sha256:old", Image: "sha256:old"}}},
+		}},
+	}
+	revertedTags := func(expected ...string) func(*testing.T, ctrlruntimeclient.Client) {
+		return func(t *testing.T, client ctrlruntimeclient.Client) {
+			stream := &imageapi.ImageStream{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ocp", Name: "4.8"}, stream); err != nil {
+				t.Fatalf("could not get the destination imagestream: %v", err)
+			}
+			var actual []string
+			for _, tag := range stream.Spec.Tags {
+				if _, ok := tag.Annotations[api.ReleaseAnnotationRevertReason]; ok && tag.From != nil {
+					actual = append(actual, tag.Name)
+				}
+			}
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Errorf("got incorrect reverted tags: %s", diff)
+			}
+		}
+	}
+	// bar and foo can be annotated with their payload eligibility and bar has aliases to prune
+	eligible := aliased.DeepCopy()
+	eligible.Spec.Tags = []imageapi.TagReference{{Name: "bar"}, {Name: "foo"}}
+	eligibleAliases := []ctrlruntimeclient.Object{eligible, aliases[1], aliases[2]}
+	payloadEligibility := func(expected map[string]string) func(*testing.T, ctrlruntimeclient.Client) {
+		return func(t *testing.T, client ctrlruntimeclient.Client) {
+			stream := &imageapi.ImageStream{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ocp", Name: "4.8"}, stream); err != nil {
+				t.Fatalf("could not get the destination imagestream: %v", err)
+			}
+			actual := map[string]string{}
+			for _, tag := range stream.Spec.Tags {
+				if eligibility, ok := tag.Annotations[api.ReleaseAnnotationPayloadEligibility]; ok {
+					actual[tag.Name] = eligibility
+				}
+			}
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Errorf("got incorrect payload eligibility: %s", diff)
+			}
+		}
+	}
+	all := func(checks ...func(*testing.T, ctrlruntimeclient.Client)) func(*testing.T, ctrlruntimeclient.Client) {
+		return func(t *testing.T, client ctrlruntimeclient.Client) {
+			for _, check := range checks {
+				check(t, client)
+			}
+		}
+	}
+	everyStep := api.PromotionConfiguration{
+		Namespace:             "ocp",
+		Name:                  "4.8",
+		Aliases:               []api.PromotionAlias{api.PromotionAliasCommit},
+		AdditionalRegistries:  []string{"quay.io"},
+		SignatureVerification: verification(api.VerificationPolicyWarn),
+		PayloadEligibility:    &api.PayloadEligibilityConfiguration{Gates: []api.PayloadEligibilityGate{api.PayloadEligibilityGateSignatureVerification}},
+		Pruning:               &api.PruningConfiguration{KeepLast: 1},
+	}
+	var testCases = []struct {
+		name            string
+		config          api.PromotionConfiguration
+		pipeline        *imageapi.ImageStream
+		objects         []ctrlruntimeclient.Object
+		failures        sets.String
+		messages        map[string]string
+		expectedPods    []string
+		expectedReasons []string
+		check           func(*testing.T, ctrlruntimeclient.Client)
+	}{
+		{
+			name:         "unchanged tags are not mirrored again when the job runs on the cluster of the registry",
+			config:       api.PromotionConfiguration{Namespace: "ocp", Name: "4.8"},
+			pipeline:     pipelineAt("registry.ci.openshift.org"),
+			objects:      []ctrlruntimeclient.Object{destination},
+			expectedPods: []string{"promotion: registry.ci.openshift.org/ocp/4.8:bar"},
+		},
+		{
+			name:         "a stale copy of the destination stream on the cluster of the job does not skip tags",
+			config:       api.PromotionConfiguration{Namespace: "ocp", Name: "4.8"},
+			pipeline:     pipelineAt("registry.build01.ci.openshift.org"),
+			objects:      []ctrlruntimeclient.Object{destination},
+			expectedPods: []string{"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo"},
+		},
+		{
+			name:         "aliases are pruned when the job runs on the cluster of the registry",
+			config:       api.PromotionConfiguration{Namespace: "ocp", Name: "4.8", Pruning: &api.PruningConfiguration{KeepLast: 1}},
+			pipeline:     pipelineAt("registry.ci.openshift.org"),
+			objects:      aliases,
+			expectedPods: []string{"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo"},
+			check:        aliasesLeft("4.8:bar-20200102-000000"),
+		},
+		{
+			name:         "aliases are not pruned on the cluster of the job when it does not serve the registry",
+			config:       api.PromotionConfiguration{Namespace: "ocp", Name: "4.8", Pruning: &api.PruningConfiguration{KeepLast: 1}},
+			pipeline:     pipelineAt("registry.build01.ci.openshift.org"),
+			objects:      aliases,
+			expectedPods: []string{"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo"},
+			check:        aliasesLeft("4.8:bar-20200101-000000", "4.8:bar-20200102-000000"),
+		},
+		{
+			name:         "reverts of mirrored tags are cleared when the job runs on the cluster of the registry",
+			config:       api.PromotionConfiguration{Namespace: "ocp", Name: "4.8"},
+			pipeline:     pipelineAt("registry.ci.openshift.org"),
+			objects:      []ctrlruntimeclient.Object{reverted},
+			expectedPods: []string{"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo"},
+			check:        revertedTags("other"),
+		},
+		{
+			name:         "reverts are not touched on the cluster of the job when it does not serve the registry",
+			config:       api.PromotionConfiguration{Namespace: "ocp", Name: "4.8"},
+			pipeline:     pipelineAt("registry.build01.ci.openshift.org"),
+			objects:      []ctrlruntimeclient.Object{reverted},
+			expectedPods: []string{"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo"},
+			check:        revertedTags("foo", "other"),
+		},
+		{
+			name: "enforced signature verification fails before the images are pushed anywhere but the promotion registry",
+			config: api.PromotionConfiguration{
+				Namespace:             "ocp",
+				Name:                  "4.8",
+				Aliases:               []api.PromotionAlias{api.PromotionAliasCommit},
+				AdditionalRegistries:  []string{"quay.io"},
+				SignatureVerification: verification(api.VerificationPolicyEnforce),
+			},
+			pipeline: pipelineAt("registry.ci.openshift.org"),
+			failures: sets.NewString("promotion-verification"),
+			expectedPods: []string{
+				"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo",
+				"promotion-verification",
+			},
+			expectedReasons: []string{"verifying_signatures"},
+		},
+		{
+			name: "signature verification that only warns lets the promotion continue",
+			config: api.PromotionConfiguration{
+				Namespace:             "ocp",
+				Name:                  "4.8",
+				Aliases:               []api.PromotionAlias{api.PromotionAliasCommit},
+				AdditionalRegistries:  []string{"quay.io"},
+				SignatureVerification: verification(api.VerificationPolicyWarn),
+			},
+			pipeline: pipelineAt("registry.ci.openshift.org"),
+			failures: sets.NewString("promotion-verification"),
+			expectedPods: []string{
+				"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo",
+				"promotion-verification",
+				"promotion-alias-0: registry.ci.openshift.org/ocp/4.8:bar-" + baseSHA + ", registry.ci.openshift.org/ocp/4.8:foo-" + baseSHA,
+				"promotion-quay-io: quay.io/ocp/4.8:bar, quay.io/ocp/4.8:foo",
+			},
+		},
+		{
+			name:     "every step runs in order and the cluster of the registry is annotated and pruned",
+			config:   everyStep,
+			pipeline: pipelineAt("registry.ci.openshift.org"),
+			objects:  eligibleAliases,
+			failures: sets.NewString("promotion-verification"),
+			messages: map[string]string{"promotion-verification": "registry.ci.openshift.org/ocp/4.8:bar"},
+			expectedPods: []string{
+				"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo",
+				"promotion-verification",
+				"promotion-alias-0: registry.ci.openshift.org/ocp/4.8:bar-" + baseSHA + ", registry.ci.openshift.org/ocp/4.8:foo-" + baseSHA,
+				"promotion-quay-io: quay.io/ocp/4.8:bar, quay.io/ocp/4.8:foo",
+			},
+			check: all(
+				payloadEligibility(map[string]string{"bar": "experimental", "foo": "eligible"}),
+				aliasesLeft("4.8:bar-20200102-000000"),
+			),
+		},
+		{
+			name:     "every step runs in order but a cluster that does not serve the registry is neither annotated nor pruned",
+			config:   everyStep,
+			pipeline: pipelineAt("registry.build01.ci.openshift.org"),
+			objects:  eligibleAliases,
+			failures: sets.NewString("promotion-verification"),
+			messages: map[string]string{"promotion-verification": "registry.ci.openshift.org/ocp/4.8:bar"},
+			expectedPods: []string{
+				"promotion: registry.ci.openshift.org/ocp/4.8:bar, registry.ci.openshift.org/ocp/4.8:foo",
+				"promotion-verification",
+				"promotion-alias-0: registry.ci.openshift.org/ocp/4.8:bar-" + baseSHA + ", registry.ci.openshift.org/ocp/4.8:foo-" + baseSHA,
+				"promotion-quay-io: quay.io/ocp/4.8:bar, quay.io/ocp/4.8:foo",
+			},
+			check: all(
+				payloadEligibility(map[string]string{}),
+				aliasesLeft("4.8:bar-20200101-000000", "4.8:bar-20200102-000000"),
+			),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			runner := &fakePodRunner{
+				WithWatch:           fakectrlruntimeclient.NewClientBuilder().WithObjects(append(testCase.objects, testCase.pipeline.DeepCopy())...).Build(),
+				failures:            testCase.failures,
+				terminationMessages: testCase.messages,
+			}
+			jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{
+				Type: prowapi.PostsubmitJob,
+				Job:  "branch-ci-org-repo-master-images",
+				Refs: &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: baseSHA},
+			}}
+			jobSpec.SetNamespace("ci-op-1")
+			config := testCase.config
+			s := &promotionStep{
+				configuration: &api.ReleaseBuildConfiguration{
+					Images:                 []api.ProjectDirectoryImageBuildStepConfiguration{{To: "bar"}, {To: "foo"}},
+					PromotionConfiguration: &config,
+				},
+				jobSpec: jobSpec,
+				client:  noLogsPodClient{PodClient: steps.NewPodClient(loggingclient.New(runner), nil, nil)},
+				censor:  secretutil.NewCensorer(),
+				probe:   func(context.Context, string) error { return nil },
+			}
+			err := s.run(context.Background())
+			if diff := cmp.Diff(testCase.expectedReasons, results.Reasons(err)); diff != "" {
+				t.Errorf("got incorrect failure reasons: %s (error: %v)", diff, err)
+			}
+			if diff := cmp.Diff(testCase.expectedPods, runner.pods); diff != "" {
+				t.Errorf("got incorrect pods: %s", diff)
+			}
+			if testCase.check != nil {
+				testCase.check(t, runner)
+			}
+		})
+	}
+}
+
+func TestWithImagesFromOtherStreams(t *testing.T) {
+	pipeline := &imageapi.ImageStream{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1", Name: "pipeline"},
+		Status: imageapi.ImageStreamStatus{Tags: []imageapi.NamedTagEventList{
+			{Tag: "src", Items: []imageapi.TagEvent{{DockerImageReference: "registry.ci.openshift.org/ci-op-1/pipeline@sha256:src", Image: "sha256:src"}}},
+		}},
+	}
+	stable := &imageapi.ImageStream{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1", Name: "stable"},
+		Status: imageapi.ImageStreamStatus{Tags: []imageapi.NamedTagEventList{
+			{Tag: "cli", Items: []imageapi.TagEvent{{DockerImageReference: "registry.ci.openshift.org/ci-op-1/stable@sha256:cli", Image: "sha256:cli"}}},
+		}},
+	}
+	s := &promotionStep{
+		jobSpec: &api.JobSpec{},
+		client:  steps.NewPodClient(loggingclient.New(fakectrlruntimeclient.NewFakeClient(stable)), nil, nil),
+	}
+	s.jobSpec.SetNamespace("ci-op-1")
+	tags := map[string]api.ImageStreamTagReference{
+		"src":        {Namespace: "ocp", Name: "4.8", Tag: "src"},
+		"stable:cli": {Namespace: "ocp", Name: "4.8", Tag: "cli"},
+		"stable:oc":  {Namespace: "ocp", Name: "4.8", Tag: "oc"},
+		"base:rhel":  {Namespace: "ocp", Name: "4.8", Tag: "rhel"},
+	}
+	resolved, err := s.withImagesFromOtherStreams(context.Background(), pipeline, tags)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:src": "registry.ci.openshift.org/ocp/4.8:src",
+		"registry.ci.openshift.org/ci-op-1/stable@sha256:cli":   "registry.ci.openshift.org/ocp/4.8:cli",
+	}
+	if diff := cmp.Diff(expected, getImageMirrorTarget(tags, indexPipeline(resolved), "registry.ci.openshift.org")); diff != "" {
+		t.Errorf("got incorrect mirror targets: %s", diff)
+	}
+	if len(pipeline.Status.Tags) != 1 {
+		t.Errorf("expected the pipeline to be left alone, got tags %v", pipeline.Status.Tags)
+	}
+}
+
+func TestPushedDestinations(t *testing.T) {
+	imageMirrorTarget := map[string]string{
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:a": "registry.ci.openshift.org/ocp/4.8:a",
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:b": "registry.ci.openshift.org/ocp/4.8:b",
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:c": "registry.ci.openshift.org/ocp/4.8:c",
+	}
+	remaining := map[string]string{
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:b": "registry.ci.openshift.org/ocp/4.8:b",
+	}
+	expected := []string{"registry.ci.openshift.org/ocp/4.8:a", "registry.ci.openshift.org/ocp/4.8:c"}
+	if diff := cmp.Diff(expected, pushedDestinations(imageMirrorTarget, remaining)); diff != "" {
+		t.Errorf("got incorrect pushed destinations: %s", diff)
+	}
+	if pushed := pushedDestinations(imageMirrorTarget, imageMirrorTarget); len(pushed) != 0 {
+		t.Errorf("expected nothing to be pushed, got %v", pushed)
+	}
+}
+
+func TestDeleteInterruptedPod(t *testing.T) {
+	client := fakectrlruntimeclient.NewFakeClient(&coreapi.Pod{ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1", Name: "promotion"}})
+	s := &promotionStep{
+		jobSpec: &api.JobSpec{},
+		client:  steps.NewPodClient(loggingclient.New(client), nil, nil),
+	}
+	s.jobSpec.SetNamespace("ci-op-1")
+	s.deleteInterruptedPod("promotion")
+	err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ci-op-1", Name: "promotion"}, &coreapi.Pod{})
+	if !kerrors.IsNotFound(err) {
+		t.Errorf("expected the pod to be deleted, got: %v", err)
+	}
+	// deleting a pod that is already gone is not an error
+	s.deleteInterruptedPod("promotion")
+}
+
+func TestRunPodDeletesInterruptedPods(t *testing.T) {
+	client := fakectrlruntimeclient.NewFakeClient()
+	s := &promotionStep{
+		jobSpec: &api.JobSpec{},
+		client:  steps.NewPodClient(loggingclient.New(client), nil, nil),
+	}
+	s.jobSpec.SetNamespace("ci-op-1")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	pod := &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1", Name: "promotion-verification"},
+		Spec:       coreapi.PodSpec{RestartPolicy: coreapi.RestartPolicyNever, Containers: []coreapi.Container{{Name: "verification"}}},
+		Status:     coreapi.PodStatus{Phase: coreapi.PodPending},
+	}
+	if _, err := s.runPod(ctx, pod); err == nil {
+		t.Fatal("expected an interrupted pod to fail, got no error")
+	}
+	err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ci-op-1", Name: "promotion-verification"}, &coreapi.Pod{})
+	if !kerrors.IsNotFound(err) {
+		t.Errorf("expected the pod to be deleted, got: %v", err)
+	}
+}
+
+func TestChangeBase(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		refs     *prowapi.Refs
+		expected string
+	}{
+		{
+			name: "no refs",
+		},
+		{
+			name:     "pull request diffs against its base",
+			refs:     &prowapi.Refs{BaseSHA: "base", BaseLink: "https://github.com/org/repo/commit/base", Pulls: []prowapi.Pull{{Number: 1}}},
+			expected: "base",
+		},
+		{
+			name:     "push diffs against the branch before the push",
+			refs:     &prowapi.Refs{BaseSHA: "after", BaseLink: "https://github.com/org/repo/compare/before...after"},
+			expected: "before",
+		},
+		{
+			name: "push that created the branch has no base",
+			refs: &prowapi.Refs{BaseSHA: "after", BaseLink: "https://github.com/org/repo/compare/0000000000000000000000000000000000000000...after"},
+		},
+		{
+			name: "push without a compare link has no base",
+			refs: &prowapi.Refs{BaseSHA: "after", BaseLink: "https://github.com/org/repo/commit/after"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := changeBase(testCase.refs); actual != testCase.expected {
+				t.Errorf("expected base %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package release
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+const (
+	// PromotionManifestVersion is the schema version of the promotion manifests written by ci-operator
+	PromotionManifestVersion = "v1"
+	// PromotionManifestFilename is the artifact the promotion manifest is written to
+	PromotionManifestFilename = "promotion-manifest.json"
+)
+
+// PromotionManifest describes the images published by a promotion, so that
+// release orchestration outside of CI can pick them up without scraping logs.
+type PromotionManifest struct {
+	// Version is the schema version of the manifest
+	Version string `json:"version"`
+	// Job is the name of the job that promoted the images
+	Job string `json:"job,omitempty"`
+	// BuildID is the ID of the job run that promoted the images
+	BuildID string `json:"build_id,omitempty"`
+	// Rehearsal is set when the images were promoted into the namespace of a
+	// rehearsal or presubmit job instead of the configured destination
+	Rehearsal bool `json:"rehearsal,omitempty"`
+	// Sources are the commits the promoted images were built from
+	Sources []PromotionSource `json:"sources,omitempty"`
+	// Tags are the destination tags that were promoted
+	Tags []PromotedTag `json:"tags"`
+	// BuildCache is the outcome of pushing the build cache, if one was promoted
+	BuildCache *BuildCacheStatus `json:"build_cache,omitempty"`
+	// AdditionalRegistries is the outcome of the promotion to each of the
+	// additional registries
+	AdditionalRegistries []RegistryStatus `json:"additional_registries,omitempty"`
+	// Skipped are the destination tags that were not promoted because the
+	// pipeline holds no image for them
+	Skipped []SkippedTag `json:"skipped,omitempty"`
+	// Archive lists the copies of the promoted images in the long-term archive
+	Archive []ArchivedImage `json:"archive,omitempty"`
+}
+
+// RegistryStatus describes the promotion to an additional registry. The promoted
+// tags are pushed to the same repositories as in the primary registry.
+type RegistryStatus struct {
+	// Registry is the domain of the additional registry
+	Registry string `json:"registry"`
+	// Promoted is set when every tag was pushed to the registry
+	Promoted bool `json:"promoted"`
+	// Error describes why the tags could not be pushed to the registry
+	Error string `json:"error,omitempty"`
+}
+
+// ArchivedImage is a write-once copy of a promoted image in the archive
+type ArchivedImage struct {
+	// PullSpec is the archived tag of the image
+	PullSpec string `json:"pull_spec"`
+	// Digest is the digest of the archived image
+	Digest string `json:"digest"`
+	// RetainUntil is the time until which the archived image must be kept, in RFC 3339 format
+	RetainUntil string `json:"retain_until,omitempty"`
+}
+
+// BuildCacheStatus describes the best-effort push of the build cache
+type BuildCacheStatus struct {
+	// PullSpec is the destination the build cache was pushed to
+	PullSpec string `json:"pull_spec"`
+	// Pushed is set when the build cache was pushed successfully
+	Pushed bool `json:"pushed"`
+	// Error describes why the build cache could not be pushed
+	Error string `json:"error,omitempty"`
+}
+
+// PromotionSource identifies a commit that promoted images were built from
+type PromotionSource struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Commit string `json:"commit"`
+}
+
+// PromotedTag is a single tag published by a promotion
+type PromotedTag struct {
+	// Source is the tag in the pipeline image stream the image was promoted from
+	Source string `json:"source,omitempty"`
+	// Namespace and Name identify the destination image stream
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Tag is the destination tag in the image stream
+	Tag string `json:"tag"`
+	// PullSpec is the destination the image was pushed to
+	PullSpec string `json:"pull_spec"`
+	// Digest is the digest of the promoted image
+	Digest string `json:"digest"`
+	// Aliases are the pull specs of the additional tags of the image
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// SkippedTag is a destination tag that was configured for promotion but not promoted
+type SkippedTag struct {
+	// Namespace, Name and Tag identify the destination that was not promoted
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Tag       string `json:"tag"`
+	// Source is the tag in the pipeline image stream that holds no image
+	Source string `json:"source"`
+}
+
+// ParsePromotionManifest decodes and validates a promotion manifest
+func ParsePromotionManifest(data []byte) (*PromotionManifest, error) {
+	var manifest PromotionManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("could not unmarshal promotion manifest: %w", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Validate ensures the manifest is of a known version and fully describes every tag
+func (m *PromotionManifest) Validate() error {
+	if m.Version != PromotionManifestVersion {
+		return fmt.Errorf("unsupported promotion manifest version %q, expected %q", m.Version, PromotionManifestVersion)
+	}
+	var errs []error
+	for i, source := range m.Sources {
+		if source.Org == "" || source.Repo == "" || source.Commit == "" {
+			errs = append(errs, fmt.Errorf("sources[%d]: org, repo and commit are required", i))
+		}
+	}
+	if len(m.Tags) == 0 && m.BuildCache == nil {
+		errs = append(errs, errors.New("tags: no promoted tags listed"))
+	}
+	if m.BuildCache != nil && m.BuildCache.PullSpec == "" {
+		errs = append(errs, errors.New("build_cache: pull_spec is required"))
+	}
+	for i, tag := range m.Tags {
+		if tag.Namespace == "" || tag.Name == "" || tag.Tag == "" || tag.PullSpec == "" {
+			errs = append(errs, fmt.Errorf("tags[%d]: namespace, name, tag and pull_spec are required", i))
+		}
+		if !strings.HasPrefix(tag.Digest, "sha256:") {
+			errs = append(errs, fmt.Errorf("tags[%d]: invalid digest %q", i, tag.Digest))
+		}
+	}
+	for i, image := range m.Archive {
+		if image.PullSpec == "" {
+			errs = append(errs, fmt.Errorf("archive[%d]: pull_spec is required", i))
+		}
+		if !strings.HasPrefix(image.Digest, "sha256:") {
+			errs = append(errs, fmt.Errorf("archive[%d]: invalid digest %q", i, image.Digest))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// promotionManifestFor describes the tags that were mirrored out of the pipeline
+func promotionManifestFor(jobSpec *api.JobSpec, tags map[string]api.ImageStreamTagReference, images *pipelineIndex, registry string) PromotionManifest {
+	manifest := PromotionManifest{
+		Version: PromotionManifestVersion,
+		Job:     jobSpec.Job,
+		BuildID: jobSpec.BuildID,
+		Tags:    []PromotedTag{},
+		Skipped: skippedTags(tags, images),
+	}
+	var refs []prowapi.Refs
+	if jobSpec.Refs != nil {
+		refs = append(refs, *jobSpec.Refs)
+	}
+	refs = append(refs, jobSpec.ExtraRefs...)
+	for _, ref := range refs {
+		manifest.Sources = append(manifest.Sources, PromotionSource{Org: ref.Org, Repo: ref.Repo, Branch: ref.BaseRef, Commit: ref.BaseSHA})
+	}
+
+	for src, dst := range tags {
+		event, ok := images.events[src]
+		if !ok || event.DockerImageReference == "" {
+			continue
+		}
+		manifest.Tags = append(manifest.Tags, PromotedTag{
+			Source:    src,
+			Namespace: dst.Namespace,
+			Name:      dst.Name,
+			Tag:       dst.Tag,
+			PullSpec:  fmt.Sprintf("%s/%s", registry, dst.ISTagName()),
+			Digest:    event.Image,
+		})
+	}
+	sort.Slice(manifest.Tags, func(i, j int) bool {
+		return manifest.Tags[i].PullSpec < manifest.Tags[j].PullSpec
+	})
+	return manifest
+}
+
+// skippedTags lists the destination tags for which the pipeline holds no image
+func skippedTags(tags map[string]api.ImageStreamTagReference, images *pipelineIndex) []SkippedTag {
+	var skipped []SkippedTag
+	for src, dst := range tags {
+		if images.events[src].DockerImageReference != "" {
+			continue
+		}
+		skipped = append(skipped, SkippedTag{Namespace: dst.Namespace, Name: dst.Name, Tag: dst.Tag, Source: src})
+	}
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Source < skipped[j].Source
+	})
+	return skipped
+}
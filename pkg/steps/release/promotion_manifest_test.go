@@ -0,0 +1,110 @@
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+
+	imageapi "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestPromotionManifestFor(t *testing.T) {
+	jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{
+		Job:     "branch-ci-org-repo-master-images",
+		BuildID: "1234",
+		Refs:    &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "abcdef"},
+	}}
+	tags := map[string]api.ImageStreamTagReference{
+		"b":       {Namespace: "ci", Name: "b", Tag: "latest"},
+		"a":       {Namespace: "ci", Name: "a", Tag: "latest"},
+		"missing": {Namespace: "ci", Name: "missing", Tag: "latest"},
+	}
+	pipeline := &imageapi.ImageStream{
+		Status: imageapi.ImageStreamStatus{
+			Tags: []imageapi.NamedTagEventList{
+				{Tag: "a", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:aaa", Image: "sha256:aaa"}}},
+				{Tag: "b", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:bbb", Image: "sha256:bbb"}}},
+			},
+		},
+	}
+	expected := PromotionManifest{
+		Version: "v1",
+		Job:     "branch-ci-org-repo-master-images",
+		BuildID: "1234",
+		Sources: []PromotionSource{{Org: "org", Repo: "repo", Branch: "master", Commit: "abcdef"}},
+		Tags: []PromotedTag{
+			{Source: "a", Namespace: "ci", Name: "a", Tag: "latest", PullSpec: "registry.ci.openshift.org/ci/a:latest", Digest: "sha256:aaa"},
+			{Source: "b", Namespace: "ci", Name: "b", Tag: "latest", PullSpec: "registry.ci.openshift.org/ci/b:latest", Digest: "sha256:bbb"},
+		},
+		Skipped: []SkippedTag{{Namespace: "ci", Name: "missing", Tag: "latest", Source: "missing"}},
+	}
+	if diff := cmp.Diff(expected, promotionManifestFor(jobSpec, tags, indexPipeline(pipeline), "registry.ci.openshift.org")); diff != "" {
+		t.Errorf("got incorrect promotion manifest: %v", diff)
+	}
+}
+
+func TestParsePromotionManifest(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		data          string
+		expected      *PromotionManifest
+		expectedError error
+	}{
+		{
+			name: "valid manifest",
+			data: `{"version":"v1","sources":[{"org":"org","repo":"repo","branch":"master","commit":"abcdef"}],"tags":[{"source":"a","namespace":"ci","name":"a","tag":"latest","pull_spec":"registry.ci.openshift.org/ci/a:latest","digest":"sha256:aaa"}]}`,
+			expected: &PromotionManifest{
+				Version: "v1",
+				Sources: []PromotionSource{{Org: "org", Repo: "repo", Branch: "master", Commit: "abcdef"}},
+				Tags:    []PromotedTag{{Source: "a", Namespace: "ci", Name: "a", Tag: "latest", PullSpec: "registry.ci.openshift.org/ci/a:latest", Digest: "sha256:aaa"}},
+			},
+		},
+		{
+			name:          "unknown version",
+			data:          `{"version":"v2"}`,
+			expectedError: errors.New(`unsupported promotion manifest version "v2", expected "v1"`),
+		},
+		{
+			name:          "incomplete manifest",
+			data:          `{"version":"v1","sources":[{"org":"org"}],"tags":[{"namespace":"ci","name":"a","tag":"latest","digest":"aaa"}]}`,
+			expectedError: errors.New(`[sources[0]: org, repo and commit are required, tags[0]: namespace, name, tag and pull_spec are required, tags[0]: invalid digest "aaa"]`),
+		},
+		{
+			name: "build cache only",
+			data: `{"version":"v1","tags":[],"build_cache":{"pull_spec":"registry.ci.openshift.org/build-cache/org-repo:master","pushed":false,"error":"timed out"}}`,
+			expected: &PromotionManifest{
+				Version:    "v1",
+				Tags:       []PromotedTag{},
+				BuildCache: &BuildCacheStatus{PullSpec: "registry.ci.openshift.org/build-cache/org-repo:master", Error: "timed out"},
+			},
+		},
+		{
+			name:          "invalid archive",
+			data:          `{"version":"v1","tags":[{"namespace":"ci","name":"a","tag":"latest","pull_spec":"registry.ci.openshift.org/ci/a:latest","digest":"sha256:aaa"}],"archive":[{"digest":"aaa"}]}`,
+			expectedError: errors.New(`[archive[0]: pull_spec is required, archive[0]: invalid digest "aaa"]`),
+		},
+		{
+			name:          "no tags",
+			data:          `{"version":"v1"}`,
+			expectedError: errors.New("tags: no promoted tags listed"),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			manifest, err := ParsePromotionManifest([]byte(testCase.data))
+			if diff := cmp.Diff([]error{testCase.expectedError}, []error{err}, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("got incorrect error: %v", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, manifest); diff != "" {
+				t.Errorf("got incorrect manifest: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package release
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/secretutil"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+// PromotionMetricsFilename is the artifact the promotion metrics are written to
+const PromotionMetricsFilename = "promotion-metrics.json"
+
+// PromotionMetrics describes how long a promotion took and how reliably it went,
+// so that promotion reliability and registry slowdowns can be tracked across jobs.
+// ci-operator exposes no metrics endpoint, so these are only written as a JSON
+// artifact of the job and have to be aggregated from the job artifacts.
+type PromotionMetrics struct {
+	// DurationSeconds is how long the whole promotion took
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Images is the number of tags configured for promotion
+	Images int `json:"images"`
+	// Skipped is the number of tags not promoted because the pipeline holds no image for them
+	Skipped int `json:"skipped"`
+	// Unchanged is the number of tags not mirrored because they were already up to date
+	Unchanged int `json:"unchanged"`
+	// Mirrors describes every mirror pod that ran
+	Mirrors []MirrorMetrics `json:"mirrors,omitempty"`
+	// FailureReasons are the reasons the promotion failed for, if it did
+	FailureReasons []string `json:"failure_reasons,omitempty"`
+}
+
+// MirrorMetrics describes a single mirror pod, including its retries
+type MirrorMetrics struct {
+	// Pod is the name of the mirror pod
+	Pod string `json:"pod"`
+	// Images is the number of images the pod mirrored
+	Images int `json:"images"`
+	// Attempts is how many times the pod ran
+	Attempts int `json:"attempts"`
+	// Failed is the number of images that were never mirrored
+	Failed int `json:"failed"`
+	// DurationSeconds is how long mirroring took, including retries
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Interrupted is set when the promotion was cancelled while the pod ran
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Pushed are the destinations confirmed pushed before an interruption
+	Pushed []string `json:"pushed,omitempty"`
+}
+
+// promotionMetricsRecorder collects the metrics of a promotion. Mirror pods run in
+// parallel, so recording is synchronized. A nil recorder records nothing.
+type promotionMetricsRecorder struct {
+	lock    sync.Mutex
+	metrics PromotionMetrics
+}
+
+func (r *promotionMetricsRecorder) setImages(images, skipped int) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics.Images = images
+	r.metrics.Skipped = skipped
+}
+
+func (r *promotionMetricsRecorder) setUnchanged(unchanged int) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics.Unchanged = unchanged
+}
+
+func (r *promotionMetricsRecorder) recordMirror(mirror MirrorMetrics) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics.Mirrors = append(r.metrics.Mirrors, mirror)
+}
+
+// finish completes the metrics with the outcome of the promotion
+func (r *promotionMetricsRecorder) finish(duration time.Duration, err error) PromotionMetrics {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	metrics := r.metrics
+	metrics.DurationSeconds = duration.Seconds()
+	metrics.FailureReasons = results.Reasons(err)
+	metrics.Mirrors = append([]MirrorMetrics(nil), r.metrics.Mirrors...)
+	sort.Slice(metrics.Mirrors, func(i, j int) bool {
+		return metrics.Mirrors[i].Pod < metrics.Mirrors[j].Pod
+	})
+	return metrics
+}
+
+// save writes the metrics to an artifact, redacting secrets with the censor
+func (r *promotionMetricsRecorder) save(censor secretutil.Censorer, duration time.Duration, err error) {
+	if r == nil {
+		return
+	}
+	data, marshalErr := json.MarshalIndent(r.finish(duration, err), "", "  ")
+	if marshalErr != nil {
+		logrus.WithError(marshalErr).Warn("Could not marshal the promotion metrics.")
+		return
+	}
+	if saveErr := api.SaveArtifact(censor, PromotionMetricsFilename, data); saveErr != nil {
+		logrus.WithError(saveErr).Warn("Could not save the promotion metrics.")
+	}
+}
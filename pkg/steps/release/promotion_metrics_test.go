@@ -0,0 +1,45 @@
+package release
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/test-infra/prow/secretutil"
+
+	"github.com/openshift/ci-tools/pkg/results"
+)
+
+func TestPromotionMetricsRecorder(t *testing.T) {
+	recorder := &promotionMetricsRecorder{}
+	recorder.setImages(3, 1)
+	recorder.setUnchanged(1)
+	recorder.recordMirror(MirrorMetrics{Pod: "promotion-1", Images: 1, Attempts: 3, Failed: 1, DurationSeconds: 90})
+	recorder.recordMirror(MirrorMetrics{Pod: "promotion-0", Images: 1, Attempts: 1, DurationSeconds: 10})
+
+	err := results.ForReason("promoting_images").ForError(results.ForReason("mirroring_images").ForError(errors.New("could not push")))
+	expected := PromotionMetrics{
+		DurationSeconds: 120,
+		Images:          3,
+		Skipped:         1,
+		Unchanged:       1,
+		Mirrors: []MirrorMetrics{
+			{Pod: "promotion-0", Images: 1, Attempts: 1, DurationSeconds: 10},
+			{Pod: "promotion-1", Images: 1, Attempts: 3, Failed: 1, DurationSeconds: 90},
+		},
+		FailureReasons: []string{"promoting_images:mirroring_images"},
+	}
+	if diff := cmp.Diff(expected, recorder.finish(2*time.Minute, err)); diff != "" {
+		t.Errorf("got incorrect metrics: %v", diff)
+	}
+}
+
+func TestNilPromotionMetricsRecorder(t *testing.T) {
+	var recorder *promotionMetricsRecorder
+	recorder.setImages(1, 0)
+	recorder.setUnchanged(1)
+	recorder.recordMirror(MirrorMetrics{Pod: "promotion"})
+	recorder.save(secretutil.NewCensorer(), time.Second, nil)
+}
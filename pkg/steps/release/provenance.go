@@ -0,0 +1,219 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/steps"
+)
+
+const (
+	// provenanceBuildType identifies ci-operator builds in SLSA provenance
+	provenanceBuildType = "https://github.com/openshift/ci-tools/ci-operator@v1"
+	// provenanceBuilderID identifies the CI system that ran the build
+	provenanceBuilderID = "https://prow.ci.openshift.org"
+)
+
+// provenancePredicate is the predicate of a SLSA v1 provenance attestation
+type provenancePredicate struct {
+	BuildDefinition buildDefinition `json:"buildDefinition"`
+	RunDetails      runDetails      `json:"runDetails"`
+}
+
+type buildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   externalParameters   `json:"externalParameters"`
+	ResolvedDependencies []resourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+type externalParameters struct {
+	Job     string            `json:"job"`
+	Sources []PromotionSource `json:"sources,omitempty"`
+}
+
+type resourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+type runDetails struct {
+	Builder  builder       `json:"builder"`
+	Metadata buildMetadata `json:"metadata"`
+}
+
+type builder struct {
+	ID string `json:"id"`
+}
+
+type buildMetadata struct {
+	InvocationID string `json:"invocationId"`
+}
+
+// provenanceFor describes the job that built the promoted images: the commits it
+// built from and the inputs the build steps resolved
+func provenanceFor(jobSpec *api.JobSpec, inputs []resourceDescriptor) provenancePredicate {
+	predicate := provenancePredicate{
+		BuildDefinition: buildDefinition{
+			BuildType:          provenanceBuildType,
+			ExternalParameters: externalParameters{Job: jobSpec.Job},
+		},
+		RunDetails: runDetails{
+			Builder:  builder{ID: provenanceBuilderID},
+			Metadata: buildMetadata{InvocationID: jobSpec.BuildID},
+		},
+	}
+	var refs []prowapi.Refs
+	if jobSpec.Refs != nil {
+		refs = append(refs, *jobSpec.Refs)
+	}
+	refs = append(refs, jobSpec.ExtraRefs...)
+	for _, ref := range refs {
+		predicate.BuildDefinition.ExternalParameters.Sources = append(predicate.BuildDefinition.ExternalParameters.Sources, PromotionSource{Org: ref.Org, Repo: ref.Repo, Branch: ref.BaseRef, Commit: ref.BaseSHA})
+		predicate.BuildDefinition.ResolvedDependencies = append(predicate.BuildDefinition.ResolvedDependencies, resourceDescriptor{
+			URI:    fmt.Sprintf("git+https://github.com/%s/%s@refs/heads/%s", ref.Org, ref.Repo, ref.BaseRef),
+			Digest: map[string]string{"gitCommit": ref.BaseSHA},
+		})
+	}
+	predicate.BuildDefinition.ResolvedDependencies = append(predicate.BuildDefinition.ResolvedDependencies, inputs...)
+	return predicate
+}
+
+// inputDependencies describes the images the build steps report as their inputs, which
+// are the digests the base images of the build were resolved to. Inputs that are not
+// image digests, e.g. the refs of the job, are described by the sources instead.
+func inputDependencies(inputSteps []api.Step) ([]resourceDescriptor, error) {
+	var dependencies []resourceDescriptor
+	for _, step := range inputSteps {
+		inputs, err := step.Inputs()
+		if err != nil {
+			return nil, fmt.Errorf("could not determine inputs for step %s: %w", step.Name(), err)
+		}
+		seen := sets.NewString()
+		for _, input := range inputs {
+			digest := strings.SplitN(input, ":", 2)
+			if len(digest) != 2 || digest[0] != "sha256" || seen.Has(input) {
+				continue
+			}
+			seen.Insert(input)
+			dependencies = append(dependencies, resourceDescriptor{
+				Name:   step.Name(),
+				Digest: map[string]string{digest[0]: digest[1]},
+			})
+		}
+	}
+	return dependencies, nil
+}
+
+// attestationSubjects lists the digest references of the promoted images, which are
+// what attestations are attached to
+func attestationSubjects(tags map[string]api.ImageStreamTagReference, images *pipelineIndex, registry string) []string {
+	events := images.events
+	subjects := map[string]bool{}
+	for src, dst := range tags {
+		if image := events[src].Image; image != "" {
+			subjects[fmt.Sprintf("%s/%s/%s@%s", registry, dst.Namespace, dst.Name, image)] = true
+		}
+	}
+	var sorted []string
+	for subject := range subjects {
+		sorted = append(sorted, subject)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// copyProvenanceCredentials copies the credentials cosign needs into the job namespace,
+// so that they can be mounted into the pod attaching the attestations. Only secrets in
+// the promotion credentials namespace are copied.
+func copyProvenanceCredentials(ctx context.Context, client ctrlruntimeclient.Client, namespace string, credentials []api.CredentialReference) error {
+	for _, credential := range credentials {
+		if credential.Namespace != api.PromotionCredentialsNamespace {
+			return fmt.Errorf("credential %s/%s is not in the %s namespace", credential.Namespace, credential.Name, api.PromotionCredentialsNamespace)
+		}
+	}
+	if err := steps.CopyCredentials(ctx, client, namespace, credentials); err != nil {
+		return fmt.Errorf("could not copy provenance credentials: %w", err)
+	}
+	return nil
+}
+
+// getProvenancePod returns a pod that attaches the provenance attestation to every subject
+func getProvenancePod(subjects []string, predicate provenancePredicate, provenance api.ProvenanceConfiguration, namespace string) (*coreapi.Pod, error) {
+	raw, err := json.Marshal(predicate)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal provenance: %w", err)
+	}
+	var images []string
+	for _, subject := range subjects {
+		images = append(images, shellQuote(subject))
+	}
+	script := fmt.Sprintf(`printf '%%s' "$PROVENANCE" > /tmp/provenance.json
+failed=0
+for image in %s; do
+  cosign attest --yes --key=%s --type=slsaprovenance1 --predicate=/tmp/provenance.json "$image" || failed=1
+done
+exit $failed`, strings.Join(images, " "), shellQuote(provenance.Key))
+	env := []coreapi.EnvVar{
+		{Name: "DOCKER_CONFIG", Value: api.RegistryPushCredentialsCICentralSecretMountPath},
+		{Name: "PROVENANCE", Value: string(raw)},
+	}
+	var names []string
+	for name := range provenance.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, coreapi.EnvVar{Name: name, Value: provenance.Env[name]})
+	}
+	mounts := []coreapi.VolumeMount{
+		{
+			Name:      "push-secret",
+			MountPath: api.RegistryPushCredentialsCICentralSecretMountPath,
+			ReadOnly:  true,
+		},
+	}
+	volumes := []coreapi.Volume{
+		{
+			Name: "push-secret",
+			VolumeSource: coreapi.VolumeSource{
+				Secret: &coreapi.SecretVolumeSource{
+					SecretName: promotionRegistryConfigSecret,
+					Items:      []coreapi.KeyToPath{{Key: coreapi.DockerConfigJsonKey, Path: "config.json"}},
+				},
+			},
+		},
+	}
+	pod := &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "promotion-provenance",
+			Namespace: namespace,
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			Containers: []coreapi.Container{
+				{
+					Name:         "provenance",
+					Image:        promotionPodImage(provenance.Image),
+					Command:      []string{"/bin/sh", "-c"},
+					Args:         []string{script},
+					Env:          env,
+					VolumeMounts: mounts,
+				},
+			},
+			Volumes: volumes,
+		},
+	}
+	steps.AddCredentials(provenance.Credentials, pod)
+	return pod, nil
+}
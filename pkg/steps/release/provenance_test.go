@@ -0,0 +1,182 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imageapi "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestProvenanceFor(t *testing.T) {
+	jobSpec := &api.JobSpec{JobSpec: downwardapi.JobSpec{
+		Job:     "branch-ci-org-repo-master-images",
+		BuildID: "1234",
+		Refs:    &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "abcdef"},
+	}}
+	inputs := []resourceDescriptor{{Name: "[input:root]", Digest: map[string]string{"sha256": "rrr"}}}
+	expected := provenancePredicate{
+		BuildDefinition: buildDefinition{
+			BuildType: provenanceBuildType,
+			ExternalParameters: externalParameters{
+				Job:     "branch-ci-org-repo-master-images",
+				Sources: []PromotionSource{{Org: "org", Repo: "repo", Branch: "master", Commit: "abcdef"}},
+			},
+			ResolvedDependencies: []resourceDescriptor{
+				{URI: "git+https://github.com/org/repo@refs/heads/master", Digest: map[string]string{"gitCommit": "abcdef"}},
+				{Name: "[input:root]", Digest: map[string]string{"sha256": "rrr"}},
+			},
+		},
+		RunDetails: runDetails{
+			Builder:  builder{ID: provenanceBuilderID},
+			Metadata: buildMetadata{InvocationID: "1234"},
+		},
+	}
+	if diff := cmp.Diff(expected, provenanceFor(jobSpec, inputs)); diff != "" {
+		t.Errorf("got incorrect provenance: %v", diff)
+	}
+}
+
+// inputStep is a build step that only reports its inputs
+type inputStep struct {
+	api.Step
+	name   string
+	inputs api.InputDefinition
+	err    error
+}
+
+func (s inputStep) Name() string                         { return s.name }
+func (s inputStep) Inputs() (api.InputDefinition, error) { return s.inputs, s.err }
+
+func TestInputDependencies(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		steps         []api.Step
+		expected      []resourceDescriptor
+		expectedError error
+	}{
+		{
+			name: "image digests are recorded, other inputs are not",
+			steps: []api.Step{
+				inputStep{name: "[input:root]", inputs: api.InputDefinition{"sha256:rrr"}},
+				inputStep{name: "src", inputs: api.InputDefinition{"org,repo=master:abcdef"}},
+				inputStep{name: "[input:base]", inputs: api.InputDefinition{"sha256:bbb", "sha256:bbb"}},
+				inputStep{name: "bin"},
+			},
+			expected: []resourceDescriptor{
+				{Name: "[input:root]", Digest: map[string]string{"sha256": "rrr"}},
+				{Name: "[input:base]", Digest: map[string]string{"sha256": "bbb"}},
+			},
+		},
+		{
+			name:          "unresolvable inputs fail",
+			steps:         []api.Step{inputStep{name: "[input:root]", err: errors.New("not found")}},
+			expectedError: errors.New("could not determine inputs for step [input:root]: not found"),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := inputDependencies(testCase.steps)
+			if diff := cmp.Diff([]error{testCase.expectedError}, []error{err}, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("got incorrect error: %v", diff)
+			}
+			if diff := cmp.Diff(testCase.expected, actual); diff != "" {
+				t.Errorf("got incorrect dependencies: %v", diff)
+			}
+		})
+	}
+}
+
+func TestCopyProvenanceCredentials(t *testing.T) {
+	client := fakectrlruntimeclient.NewFakeClient(
+		&coreapi.Secret{
+			ObjectMeta: meta.ObjectMeta{Namespace: "test-credentials", Name: "kms"},
+			Data:       map[string][]byte{"credentials.json": []byte("{}")},
+		},
+		&coreapi.Secret{
+			ObjectMeta: meta.ObjectMeta{Namespace: "ci", Name: "kms"},
+			Data:       map[string][]byte{"credentials.json": []byte("{}")},
+		},
+	)
+	credentials := []api.CredentialReference{{Namespace: "test-credentials", Name: "kms", MountPath: "/var/run/kms"}}
+	// copying twice leaves the existing copy alone
+	for i := 0; i < 2; i++ {
+		if err := copyProvenanceCredentials(context.Background(), client, "ci-op-1", credentials); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	secret := &coreapi.Secret{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ci-op-1", Name: "test-credentials-kms"}, secret); err != nil {
+		t.Fatalf("could not get the copied credential: %v", err)
+	}
+	if diff := cmp.Diff(map[string][]byte{"credentials.json": []byte("{}")}, secret.Data); diff != "" {
+		t.Errorf("got incorrect credential: %v", diff)
+	}
+	missing := []api.CredentialReference{{Namespace: "test-credentials", Name: "missing", MountPath: "/var/run/missing"}}
+	if err := copyProvenanceCredentials(context.Background(), client, "ci-op-1", missing); err == nil {
+		t.Error("expected a missing credential to fail, got no error")
+	}
+	other := []api.CredentialReference{{Namespace: "ci", Name: "kms", MountPath: "/var/run/kms"}}
+	if err := copyProvenanceCredentials(context.Background(), client, "ci-op-1", other); err == nil {
+		t.Error("expected a credential outside of the credentials namespace to fail, got no error")
+	}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ci-op-1", Name: "ci-kms"}, &coreapi.Secret{}); err == nil {
+		t.Error("expected the credential outside of the credentials namespace not to be copied")
+	}
+}
+
+func TestAttestationSubjects(t *testing.T) {
+	pipeline := &imageapi.ImageStream{
+		Status: imageapi.ImageStreamStatus{
+			Tags: []imageapi.NamedTagEventList{
+				{Tag: "a", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:aaa", Image: "sha256:aaa"}}},
+				{Tag: "b", Items: []imageapi.TagEvent{{DockerImageReference: "registry/ns/pipeline@sha256:bbb", Image: "sha256:bbb"}}},
+			},
+		},
+	}
+	tags := map[string]api.ImageStreamTagReference{
+		"b":       {Namespace: "ocp", Name: "4.8", Tag: "b"},
+		"a":       {Namespace: "ocp", Name: "4.8", Tag: "a"},
+		"missing": {Namespace: "ocp", Name: "4.8", Tag: "missing"},
+	}
+	expected := []string{"quay.io/ocp/4.8@sha256:aaa", "quay.io/ocp/4.8@sha256:bbb"}
+	if diff := cmp.Diff(expected, attestationSubjects(tags, indexPipeline(pipeline), "quay.io")); diff != "" {
+		t.Errorf("got incorrect subjects: %v", diff)
+	}
+}
+
+func TestGetProvenancePod(t *testing.T) {
+	predicate := provenancePredicate{
+		BuildDefinition: buildDefinition{
+			BuildType:          provenanceBuildType,
+			ExternalParameters: externalParameters{Job: "branch-ci-org-repo-master-images"},
+		},
+		RunDetails: runDetails{
+			Builder:  builder{ID: provenanceBuilderID},
+			Metadata: buildMetadata{InvocationID: "1234"},
+		},
+	}
+	provenance := api.ProvenanceConfiguration{
+		Image:       api.ImageStreamTagReference{Namespace: "ci", Name: "cosign", Tag: "v2"},
+		Key:         "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k",
+		Credentials: []api.CredentialReference{{Namespace: "test-credentials", Name: "kms", MountPath: "/var/run/kms"}},
+		Env:         map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": "/var/run/kms/credentials.json"},
+	}
+	pod, err := getProvenancePod([]string{"registry.ci.openshift.org/ocp/4.8@sha256:aaa"}, predicate, provenance, "ci-op-zyvwvffx")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testhelper.CompareWithFixture(t, pod)
+}
@@ -0,0 +1,81 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// aliasSuffix matches the suffix of date and commit aliases of promoted tags
+var aliasSuffix = regexp.MustCompile(`-(\d{8}-\d{6}|[0-9a-f]{40})$`)
+
+// PruneAliases deletes the aliases of the given tags in the image stream that the pruning
+// policy no longer keeps and returns the tags that were deleted. Destination streams are
+// shared between repositories, so aliases of any other tag are never touched. The client
+// must be one of the cluster serving the image stream.
+func PruneAliases(ctx context.Context, client ctrlruntimeclient.Client, key ctrlruntimeclient.ObjectKey, tags sets.String, pruning api.PruningConfiguration, now time.Time) ([]string, error) {
+	stream := &imagev1.ImageStream{}
+	if err := client.Get(ctx, key, stream); err != nil {
+		return nil, fmt.Errorf("could not get imagestream %s: %w", key, err)
+	}
+	var pruned []string
+	for _, tag := range aliasesToPrune(stream, tags, pruning, now) {
+		istag := &imagev1.ImageStreamTag{ObjectMeta: meta.ObjectMeta{Namespace: key.Namespace, Name: fmt.Sprintf("%s:%s", key.Name, tag)}}
+		if err := client.Delete(ctx, istag); err != nil && !kerrors.IsNotFound(err) {
+			return pruned, fmt.Errorf("could not delete imagestreamtag %s/%s: %w", istag.Namespace, istag.Name, err)
+		}
+		pruned = append(pruned, tag)
+	}
+	return pruned, nil
+}
+
+// aliasesToPrune groups the aliases of the tags in the image stream by the tag they
+// alias and picks the ones beyond the newest KeepLast or older than MaxAge
+func aliasesToPrune(stream *imagev1.ImageStream, tags sets.String, pruning api.PruningConfiguration, now time.Time) []string {
+	type alias struct {
+		tag     string
+		created time.Time
+	}
+	aliasesByTag := map[string][]alias{}
+	for _, tag := range stream.Status.Tags {
+		loc := aliasSuffix.FindStringIndex(tag.Tag)
+		if loc == nil || loc[0] == 0 || len(tag.Items) == 0 {
+			continue
+		}
+		base := tag.Tag[:loc[0]]
+		if !tags.Has(base) {
+			continue
+		}
+		aliasesByTag[base] = append(aliasesByTag[base], alias{tag: tag.Tag, created: tag.Items[0].Created.Time})
+	}
+
+	var pruned []string
+	for _, aliases := range aliasesByTag {
+		sort.Slice(aliases, func(i, j int) bool {
+			if aliases[i].created.Equal(aliases[j].created) {
+				return aliases[i].tag > aliases[j].tag
+			}
+			return aliases[i].created.After(aliases[j].created)
+		})
+		for i, alias := range aliases {
+			tooMany := pruning.KeepLast > 0 && i >= pruning.KeepLast
+			tooOld := pruning.MaxAge != nil && now.Sub(alias.created) > pruning.MaxAge.Duration
+			if tooMany || tooOld {
+				pruned = append(pruned, alias.tag)
+			}
+		}
+	}
+	sort.Strings(pruned)
+	return pruned
+}
@@ -0,0 +1,77 @@
+package release
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+
+	imageapi "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestAliasesToPrune(t *testing.T) {
+	now := time.Date(2021, time.March, 10, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) []imageapi.TagEvent {
+		return []imageapi.TagEvent{{Created: meta.NewTime(now.AddDate(0, 0, -days))}}
+	}
+	stream := &imageapi.ImageStream{
+		Status: imageapi.ImageStreamStatus{
+			Tags: []imageapi.NamedTagEventList{
+				{Tag: "cli", Items: daysAgo(30)},
+				{Tag: "cli-20210309-000000", Items: daysAgo(1)},
+				{Tag: "cli-20210305-000000", Items: daysAgo(5)},
+				{Tag: "cli-0123456789abcdef0123456789abcdef01234567", Items: daysAgo(20)},
+				{Tag: "tests-20210301-000000", Items: daysAgo(9)},
+				{Tag: "tests-v2", Items: daysAgo(40)},
+				{Tag: "20210301-000000", Items: daysAgo(40)},
+				{Tag: "other-20210201-000000", Items: daysAgo(37)},
+				{Tag: "other-20210202-000000", Items: daysAgo(36)},
+				{Tag: "manual-0123456789abcdef0123456789abcdef01234567", Items: daysAgo(60)},
+			},
+		},
+	}
+	var testCases = []struct {
+		name     string
+		tags     sets.String
+		pruning  api.PruningConfiguration
+		expected []string
+	}{
+		{
+			name:     "keep last",
+			tags:     sets.NewString("cli", "tests"),
+			pruning:  api.PruningConfiguration{KeepLast: 2},
+			expected: []string{"cli-0123456789abcdef0123456789abcdef01234567"},
+		},
+		{
+			name:     "max age",
+			tags:     sets.NewString("cli", "tests"),
+			pruning:  api.PruningConfiguration{MaxAge: &prowapi.Duration{Duration: 7 * 24 * time.Hour}},
+			expected: []string{"cli-0123456789abcdef0123456789abcdef01234567", "tests-20210301-000000"},
+		},
+		{
+			name:     "keep last and max age",
+			tags:     sets.NewString("cli", "tests"),
+			pruning:  api.PruningConfiguration{KeepLast: 1, MaxAge: &prowapi.Duration{Duration: 7 * 24 * time.Hour}},
+			expected: []string{"cli-0123456789abcdef0123456789abcdef01234567", "cli-20210305-000000", "tests-20210301-000000"},
+		},
+		{
+			name:     "aliases of tags promoted by other repositories survive",
+			tags:     sets.NewString("cli"),
+			pruning:  api.PruningConfiguration{KeepLast: 1, MaxAge: &prowapi.Duration{Duration: 7 * 24 * time.Hour}},
+			expected: []string{"cli-0123456789abcdef0123456789abcdef01234567", "cli-20210305-000000"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, aliasesToPrune(stream, testCase.tags, testCase.pruning, now)); diff != "" {
+				t.Errorf("got incorrect aliases to prune: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+const (
+	// quayRegistry is the registry whose repositories can be provisioned through the Quay API
+	quayRegistry = "quay.io"
+	// quayAPIEndpoint is the base of the Quay API
+	quayAPIEndpoint = "https://quay.io/api/v1"
+	// quayTokenKey is the key of the OAuth token in the token secret
+	quayTokenKey = "token"
+)
+
+// quayClient creates repositories through the Quay API
+type quayClient struct {
+	client   *http.Client
+	endpoint string
+	token    string
+}
+
+func newQuayClient(token string) *quayClient {
+	return &quayClient{client: &http.Client{Timeout: 30 * time.Second}, endpoint: quayAPIEndpoint, token: token}
+}
+
+// provisionQuayRepositories creates the repositories on quay.io the images are promoted
+// to that do not exist yet, and grants the configured teams their roles on all of them
+func (s *promotionStep) provisionQuayRepositories(ctx context.Context, repositories []string, config api.QuayProvisioningConfiguration) error {
+	if len(repositories) == 0 {
+		return nil
+	}
+	if config.TokenSecret.Namespace != api.PromotionCredentialsNamespace {
+		return fmt.Errorf("token secret %s/%s is not in the %s namespace", config.TokenSecret.Namespace, config.TokenSecret.Name, api.PromotionCredentialsNamespace)
+	}
+	secret := &coreapi.Secret{}
+	if err := s.client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: config.TokenSecret.Namespace, Name: config.TokenSecret.Name}, secret); err != nil {
+		return fmt.Errorf("could not get Quay token secret %s/%s: %w", config.TokenSecret.Namespace, config.TokenSecret.Name, err)
+	}
+	token, ok := secret.Data[quayTokenKey]
+	if !ok {
+		return fmt.Errorf("token secret %s/%s has no %s key", config.TokenSecret.Namespace, config.TokenSecret.Name, quayTokenKey)
+	}
+	client := newQuayClient(string(token))
+	for _, repository := range repositories {
+		created, err := client.ensureRepository(ctx, repository, config)
+		if err != nil {
+			return fmt.Errorf("could not provision repository %s/%s: %w", quayRegistry, repository, err)
+		}
+		if created {
+			logrus.Infof("Created repository %s/%s for promotion.", quayRegistry, repository)
+		}
+	}
+	return nil
+}
+
+// pushedMirrorTargets lists everything a promotion pushes outside of the rehearsal: the
+// promoted tags, their aliases, the archive and the additional registries. Unchanged tags
+// are not mirrored, but their aliases are still pushed, so all tags are listed.
+func pushedMirrorTargets(config api.PromotionConfiguration, tags map[string]api.ImageStreamTagReference, images *pipelineIndex, registry string, imageMirrorTarget map[string]string, suffixes []string, now time.Time) []map[string]string {
+	imageMirrorTargets := []map[string]string{imageMirrorTarget}
+	for _, suffix := range suffixes {
+		imageMirrorTargets = append(imageMirrorTargets, getImageMirrorTarget(aliasTags(tags, suffix), images, registry))
+	}
+	if archive := config.Archive; archive != nil && len(imageMirrorTarget) != 0 {
+		archiveMirrorTarget, _ := getArchiveMirrorTarget(config, *archive, tags, images, registry, now)
+		for src := range archiveMirrorTarget {
+			if _, promoted := imageMirrorTarget[src]; !promoted {
+				delete(archiveMirrorTarget, src)
+			}
+		}
+		imageMirrorTargets = append(imageMirrorTargets, archiveMirrorTarget)
+	}
+	for _, additional := range config.AdditionalRegistries {
+		imageMirrorTargets = append(imageMirrorTargets, getImageMirrorTarget(tags, images, additional))
+	}
+	return imageMirrorTargets
+}
+
+// quayRepositories lists the repositories on quay.io that the targets are pushed to
+func quayRepositories(imageMirrorTargets ...map[string]string) []string {
+	seen := map[string]bool{}
+	var repositories []string
+	for _, imageMirrorTarget := range imageMirrorTargets {
+		for _, dst := range imageMirrorTarget {
+			parts := strings.SplitN(dst, "/", 2)
+			if len(parts) != 2 || parts[0] != quayRegistry {
+				continue
+			}
+			repository := parts[1]
+			if i := strings.LastIndex(repository, ":"); i != -1 {
+				repository = repository[:i]
+			}
+			if !seen[repository] {
+				seen[repository] = true
+				repositories = append(repositories, repository)
+			}
+		}
+	}
+	sort.Strings(repositories)
+	return repositories
+}
+
+// ensureRepository creates the namespace/name repository unless it exists, and grants
+// the configured teams their roles on it. Roles are granted on every run, so a grant
+// that failed after the repository was created is retried by the next promotion.
+func (c *quayClient) ensureRepository(ctx context.Context, repository string, config api.QuayProvisioningConfiguration) (bool, error) {
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("repository must be in the namespace/name format")
+	}
+	namespace, name := parts[0], parts[1]
+	status, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repository/%s/%s", url.PathEscape(namespace), url.PathEscape(name)), nil)
+	if err != nil {
+		return false, err
+	}
+	var created bool
+	switch status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		if err := c.createRepository(ctx, namespace, name, config.Visibility); err != nil {
+			return false, err
+		}
+		created = true
+	default:
+		return false, fmt.Errorf("could not look up repository: Quay responded with %d", status)
+	}
+
+	for _, team := range config.Teams {
+		path := fmt.Sprintf("/repository/%s/%s/permissions/team/%s", url.PathEscape(namespace), url.PathEscape(name), url.PathEscape(team.Name))
+		if status, err = c.do(ctx, http.MethodPut, path, map[string]string{"role": team.Role}); err != nil {
+			return created, err
+		}
+		if status != http.StatusOK {
+			return created, fmt.Errorf("could not grant team %s the %s role: Quay responded with %d", team.Name, team.Role, status)
+		}
+	}
+	return created, nil
+}
+
+func (c *quayClient) createRepository(ctx context.Context, namespace, name string, visibility api.QuayVisibility) error {
+	if visibility == "" {
+		visibility = api.QuayVisibilityPrivate
+	}
+	status, err := c.do(ctx, http.MethodPost, "/repository", map[string]string{
+		"namespace":   namespace,
+		"repository":  name,
+		"visibility":  string(visibility),
+		"description": "",
+		"repo_kind":   "image",
+	})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return fmt.Errorf("could not create repository: Quay responded with %d", status)
+	}
+	return nil
+}
+
+func (c *quayClient) do(ctx context.Context, method, path string, body interface{}) (int, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return 0, fmt.Errorf("could not marshal request: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("the Quay API is unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
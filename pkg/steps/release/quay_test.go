@@ -0,0 +1,148 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestQuayRepositories(t *testing.T) {
+	primary := map[string]string{
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:aaa": "quay.io/openshift/ci:a",
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:bbb": "quay.io/openshift/ci:b",
+	}
+	additional := map[string]string{
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:aaa": "registry.ci.openshift.org/openshift/ci:a",
+		"registry.ci.openshift.org/ci-op-1/pipeline@sha256:ccc": "quay.io/openshift/tools:c",
+	}
+	if diff := cmp.Diff([]string{"openshift/ci", "openshift/tools"}, quayRepositories(primary, additional)); diff != "" {
+		t.Errorf("got incorrect repositories: %s", diff)
+	}
+}
+
+func TestPushedMirrorTargets(t *testing.T) {
+	config := api.PromotionConfiguration{
+		Archive:              &api.PromotionArchiveConfiguration{Namespace: "archive"},
+		AdditionalRegistries: []string{"registry.example.com"},
+	}
+	tags := map[string]api.ImageStreamTagReference{
+		"cli":   {Namespace: "openshift", Name: "cli", Tag: "latest"},
+		"tests": {Namespace: "openshift", Name: "tests", Tag: "latest"},
+	}
+	images := &pipelineIndex{events: map[string]imagev1.TagEvent{
+		"cli":   {DockerImageReference: "registry.ci.openshift.org/ci-op-1/pipeline@sha256:aaa", Image: "sha256:aaa"},
+		"tests": {DockerImageReference: "registry.ci.openshift.org/ci-op-1/pipeline@sha256:bbb", Image: "sha256:bbb"},
+	}}
+	// tests is unchanged in the destination, so it is not mirrored or archived, but its alias is pushed
+	imageMirrorTarget := map[string]string{"registry.ci.openshift.org/ci-op-1/pipeline@sha256:aaa": "quay.io/openshift/cli:latest"}
+	targets := pushedMirrorTargets(config, tags, images, "quay.io", imageMirrorTarget, []string{"20210101-000000"}, time.Now())
+	if diff := cmp.Diff([]string{"archive/cli", "openshift/cli", "openshift/tests"}, quayRepositories(targets...)); diff != "" {
+		t.Errorf("got incorrect repositories: %s", diff)
+	}
+}
+
+func TestEnsureRepository(t *testing.T) {
+	config := api.QuayProvisioningConfiguration{Teams: []api.QuayTeamPermission{{Name: "owners", Role: "admin"}}}
+	var testCases = []struct {
+		name            string
+		exists          bool
+		failPermissions bool
+		expectedCreated bool
+		expectedError   bool
+		expectedCalls   []string
+	}{
+		{
+			name:   "existing repository is not created again, but teams are granted their roles",
+			exists: true,
+			expectedCalls: []string{
+				"GET /repository/openshift/ci",
+				"PUT /repository/openshift/ci/permissions/team/owners role=admin",
+			},
+		},
+		{
+			name:            "missing repository is created and teams are granted their roles",
+			expectedCreated: true,
+			expectedCalls: []string{
+				"GET /repository/openshift/ci",
+				"POST /repository namespace=openshift repository=ci visibility=private",
+				"PUT /repository/openshift/ci/permissions/team/owners role=admin",
+			},
+		},
+		{
+			name:            "failure to grant a role is an error",
+			failPermissions: true,
+			expectedCreated: true,
+			expectedError:   true,
+			expectedCalls: []string{
+				"GET /repository/openshift/ci",
+				"POST /repository namespace=openshift repository=ci visibility=private",
+				"PUT /repository/openshift/ci/permissions/team/owners role=admin",
+			},
+		},
+		{
+			name:            "failure to grant a role on an existing repository is an error",
+			exists:          true,
+			failPermissions: true,
+			expectedError:   true,
+			expectedCalls: []string{
+				"GET /repository/openshift/ci",
+				"PUT /repository/openshift/ci/permissions/team/owners role=admin",
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var calls []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				call := r.Method + " " + r.URL.Path
+				body := map[string]string{}
+				if r.Method != http.MethodGet {
+					if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+						t.Errorf("could not decode request: %v", err)
+					}
+				}
+				switch r.Method {
+				case http.MethodGet:
+					if !testCase.exists {
+						w.WriteHeader(http.StatusNotFound)
+					}
+				case http.MethodPost:
+					call += " namespace=" + body["namespace"] + " repository=" + body["repository"] + " visibility=" + body["visibility"]
+					w.WriteHeader(http.StatusCreated)
+				case http.MethodPut:
+					call += " role=" + body["role"]
+					if testCase.failPermissions {
+						w.WriteHeader(http.StatusBadRequest)
+					}
+				}
+				calls = append(calls, call)
+			}))
+			defer server.Close()
+
+			client := &quayClient{client: server.Client(), endpoint: server.URL, token: "secret"}
+			created, err := client.ensureRepository(context.Background(), "openshift/ci", config)
+			if (err != nil) != testCase.expectedError {
+				t.Errorf("expected error: %v, got: %v", testCase.expectedError, err)
+			}
+			if created != testCase.expectedCreated {
+				t.Errorf("expected created: %v, got: %v", testCase.expectedCreated, created)
+			}
+			if diff := cmp.Diff(testCase.expectedCalls, calls); diff != "" {
+				t.Errorf("got incorrect calls: %s", diff)
+			}
+		})
+	}
+}
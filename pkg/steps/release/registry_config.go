@@ -0,0 +1,148 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/kubernetes/pkg/credentialprovider"
+)
+
+// promotionRegistryConfigSecret holds the registry config assembled for the promotion pods
+const promotionRegistryConfigSecret = "promotion-registry-config"
+
+// assembleRegistryConfig merges the credentials of the dockerconfigjson push secrets into a
+// single registry config. A registry with differing credentials in two push secrets is a
+// conflict, as we could not tell which of them the promotion is meant to use. Credentials
+// from the pull secret are only used for registries none of the push secrets cover.
+func assembleRegistryConfig(pullSecret *coreapi.Secret, pushSecrets ...*coreapi.Secret) ([]byte, error) {
+	merged := credentialprovider.DockerConfig{}
+	sources := map[string]string{}
+	for _, secret := range pushSecrets {
+		if secret == nil {
+			continue
+		}
+		auths, err := readAuths(secret)
+		if err != nil {
+			return nil, err
+		}
+		for _, registry := range sortedRegistries(auths) {
+			entry := auths[registry]
+			if existing, seen := merged[registry]; seen {
+				if !reflect.DeepEqual(existing, entry) {
+					return nil, fmt.Errorf("secrets %s and %s hold different credentials for registry %s", sources[registry], secret.Name, registry)
+				}
+				continue
+			}
+			merged[registry] = entry
+			sources[registry] = secret.Name
+		}
+	}
+	if pullSecret != nil {
+		auths, err := readAuths(pullSecret)
+		if err != nil {
+			return nil, err
+		}
+		for registry, entry := range auths {
+			if _, seen := merged[registry]; !seen {
+				merged[registry] = entry
+			}
+		}
+	}
+	return json.Marshal(credentialprovider.DockerConfigJSON{Auths: merged})
+}
+
+// getPushSecrets fetches the push secrets referenced by the promotion configuration.
+// Only secrets in the promotion credentials namespace are read.
+func getPushSecrets(ctx context.Context, client ctrlruntimeclient.Client, refs []api.PushSecretReference) ([]*coreapi.Secret, error) {
+	var secrets []*coreapi.Secret
+	for _, ref := range refs {
+		if ref.Namespace != api.PromotionCredentialsNamespace {
+			return nil, fmt.Errorf("push secret %s/%s is not in the %s namespace", ref.Namespace, ref.Name, api.PromotionCredentialsNamespace)
+		}
+		secret := &coreapi.Secret{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("could not get push secret %s/%s: %w", ref.Namespace, ref.Name, err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// namespacePushSecret returns the registry credentials of the builder service account of
+// the namespace, which may push to the image streams of the namespace. Rehearsals promote
+// into the job namespace, where the central push credentials may not have push rights.
+func namespacePushSecret(ctx context.Context, client ctrlruntimeclient.Client, namespace string) (*coreapi.Secret, error) {
+	sa := &coreapi.ServiceAccount{}
+	if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: "builder"}, sa); err != nil {
+		return nil, fmt.Errorf("could not get the builder service account: %w", err)
+	}
+	for _, ref := range sa.Secrets {
+		secret := &coreapi.Secret{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("could not get secret %s of the builder service account: %w", ref.Name, err)
+		}
+		if secret.Type != coreapi.SecretTypeDockercfg {
+			continue
+		}
+		var auths credentialprovider.DockerConfig
+		if err := json.Unmarshal(secret.Data[coreapi.DockerConfigKey], &auths); err != nil {
+			return nil, fmt.Errorf("could not read registry credentials from secret %s: %w", secret.Name, err)
+		}
+		data, err := json.Marshal(credentialprovider.DockerConfigJSON{Auths: auths})
+		if err != nil {
+			return nil, fmt.Errorf("could not convert registry credentials from secret %s: %w", secret.Name, err)
+		}
+		return &coreapi.Secret{
+			ObjectMeta: meta.ObjectMeta{Namespace: namespace, Name: secret.Name},
+			Data:       map[string][]byte{coreapi.DockerConfigJsonKey: data},
+			Type:       coreapi.SecretTypeDockerConfigJson,
+		}, nil
+	}
+	return nil, fmt.Errorf("the builder service account in %s has no registry credentials", namespace)
+}
+
+func readAuths(secret *coreapi.Secret) (credentialprovider.DockerConfig, error) {
+	var config credentialprovider.DockerConfigJSON
+	if err := json.Unmarshal(secret.Data[coreapi.DockerConfigJsonKey], &config); err != nil {
+		return nil, fmt.Errorf("could not read registry credentials from secret %s: %w", secret.Name, err)
+	}
+	return config.Auths, nil
+}
+
+func sortedRegistries(auths credentialprovider.DockerConfig) []string {
+	registries := make([]string, 0, len(auths))
+	for registry := range auths {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// ensureRegistryConfigSecret stores the assembled registry config in the job namespace
+func ensureRegistryConfigSecret(ctx context.Context, client ctrlruntimeclient.Client, namespace string, config []byte) error {
+	secret := &coreapi.Secret{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      promotionRegistryConfigSecret,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{coreapi.DockerConfigJsonKey: config},
+		Type: coreapi.SecretTypeDockerConfigJson,
+	}
+	err := client.Create(ctx, secret)
+	if kerrors.IsAlreadyExists(err) {
+		err = client.Update(ctx, secret)
+	}
+	if err != nil {
+		return fmt.Errorf("could not store the promotion registry config: %w", err)
+	}
+	return nil
+}
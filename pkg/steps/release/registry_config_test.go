@@ -0,0 +1,168 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/kubernetes/pkg/credentialprovider"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func dockerConfigSecret(name, config string) *coreapi.Secret {
+	return &coreapi.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: name},
+		Data:       map[string][]byte{coreapi.DockerConfigJsonKey: []byte(config)},
+	}
+}
+
+func TestAssembleRegistryConfig(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		pullSecret    *coreapi.Secret
+		pushSecrets   []*coreapi.Secret
+		expected      credentialprovider.DockerConfig
+		expectedError error
+	}{
+		{
+			name:        "push secret only",
+			pushSecrets: []*coreapi.Secret{dockerConfigSecret("push", `{"auths":{"registry.ci.openshift.org":{"auth":"cHVzaDpzZWNyZXQ="}}}`)},
+			expected:    credentialprovider.DockerConfig{"registry.ci.openshift.org": {Username: "push", Password: "secret"}},
+		},
+		{
+			name:       "push credentials take precedence over pull credentials",
+			pullSecret: dockerConfigSecret("pull", `{"auths":{"registry.ci.openshift.org":{"auth":"cHVsbDpzZWNyZXQ="},"quay.io":{"auth":"cHVsbDpzZWNyZXQ="}}}`),
+			pushSecrets: []*coreapi.Secret{
+				dockerConfigSecret("push", `{"auths":{"registry.ci.openshift.org":{"auth":"cHVzaDpzZWNyZXQ="}}}`),
+				nil,
+			},
+			expected: credentialprovider.DockerConfig{
+				"registry.ci.openshift.org": {Username: "push", Password: "secret"},
+				"quay.io":                   {Username: "pull", Password: "secret"},
+			},
+		},
+		{
+			name: "identical push credentials are merged",
+			pushSecrets: []*coreapi.Secret{
+				dockerConfigSecret("push", `{"auths":{"registry.ci.openshift.org":{"auth":"cHVzaDpzZWNyZXQ="}}}`),
+				dockerConfigSecret("other", `{"auths":{"registry.ci.openshift.org":{"auth":"cHVzaDpzZWNyZXQ="}}}`),
+			},
+			expected: credentialprovider.DockerConfig{"registry.ci.openshift.org": {Username: "push", Password: "secret"}},
+		},
+		{
+			name: "differing push credentials conflict",
+			pushSecrets: []*coreapi.Secret{
+				dockerConfigSecret("push", `{"auths":{"registry.ci.openshift.org":{"auth":"cHVzaDpzZWNyZXQ="}}}`),
+				dockerConfigSecret("other", `{"auths":{"registry.ci.openshift.org":{"auth":"cHVsbDpzZWNyZXQ="}}}`),
+			},
+			expectedError: errors.New("secrets push and other hold different credentials for registry registry.ci.openshift.org"),
+		},
+		{
+			name:          "malformed secret",
+			pushSecrets:   []*coreapi.Secret{dockerConfigSecret("push", `{`)},
+			expectedError: errors.New("could not read registry credentials from secret push: unexpected end of JSON input"),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			raw, err := assembleRegistryConfig(testCase.pullSecret, testCase.pushSecrets...)
+			if diff := cmp.Diff([]error{testCase.expectedError}, []error{err}, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("got incorrect error: %v", diff)
+			}
+			if err != nil {
+				return
+			}
+			var actual credentialprovider.DockerConfigJSON
+			if err := json.Unmarshal(raw, &actual); err != nil {
+				t.Fatalf("could not unmarshal assembled config: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, actual.Auths); diff != "" {
+				t.Errorf("got incorrect registry config: %v", diff)
+			}
+		})
+	}
+}
+
+func TestEnsureRegistryConfigSecret(t *testing.T) {
+	client := fakectrlruntimeclient.NewFakeClient()
+	for _, config := range []string{"first", "second"} {
+		if err := ensureRegistryConfigSecret(context.Background(), client, "ci-op-zyvwvffx", []byte(config)); err != nil {
+			t.Fatalf("could not store registry config: %v", err)
+		}
+	}
+	secret := &coreapi.Secret{}
+	if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ci-op-zyvwvffx", Name: promotionRegistryConfigSecret}, secret); err != nil {
+		t.Fatalf("could not get registry config secret: %v", err)
+	}
+	if diff := cmp.Diff("second", string(secret.Data[coreapi.DockerConfigJsonKey])); diff != "" {
+		t.Errorf("registry config was not updated: %v", diff)
+	}
+}
+
+func TestGetPushSecrets(t *testing.T) {
+	secret := dockerConfigSecret("quay-push", `{"auths":{"quay.io":{"auth":"cHVzaDpzZWNyZXQ="}}}`)
+	secret.Namespace = "test-credentials"
+	client := fakectrlruntimeclient.NewClientBuilder().WithObjects(secret).Build()
+
+	secrets, err := getPushSecrets(context.Background(), client, []api.PushSecretReference{{Namespace: "test-credentials", Name: "quay-push"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(secrets) != 1 || secrets[0].Name != "quay-push" {
+		t.Errorf("expected the referenced secret, got %v", secrets)
+	}
+
+	if _, err := getPushSecrets(context.Background(), client, []api.PushSecretReference{{Namespace: "test-credentials", Name: "missing"}}); err == nil {
+		t.Error("expected an error for a missing secret, got none")
+	}
+
+	other := dockerConfigSecret("registry-push-credentials-ci-central", `{"auths":{"quay.io":{"auth":"cHVzaDpzZWNyZXQ="}}}`)
+	other.Namespace = "ci"
+	client = fakectrlruntimeclient.NewClientBuilder().WithObjects(other).Build()
+	if _, err := getPushSecrets(context.Background(), client, []api.PushSecretReference{{Namespace: "ci", Name: "registry-push-credentials-ci-central"}}); err == nil {
+		t.Error("expected an error for a secret outside of the credentials namespace, got none")
+	}
+}
+
+func TestNamespacePushSecret(t *testing.T) {
+	sa := &coreapi.ServiceAccount{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1", Name: "builder"},
+		Secrets:    []coreapi.ObjectReference{{Name: "builder-token-abcde"}, {Name: "builder-dockercfg-abcde"}},
+	}
+	token := &coreapi.Secret{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1", Name: "builder-token-abcde"},
+		Type:       coreapi.SecretTypeServiceAccountToken,
+	}
+	dockercfg := &coreapi.Secret{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ci-op-1", Name: "builder-dockercfg-abcde"},
+		Data:       map[string][]byte{coreapi.DockerConfigKey: []byte(`{"registry.build01.ci.openshift.org":{"auth":"cHVzaDpzZWNyZXQ="}}`)},
+		Type:       coreapi.SecretTypeDockercfg,
+	}
+	client := fakectrlruntimeclient.NewClientBuilder().WithObjects(sa, token, dockercfg).Build()
+
+	secret, err := namespacePushSecret(context.Background(), client, "ci-op-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	auths, err := readAuths(secret)
+	if err != nil {
+		t.Fatalf("could not read the converted credentials: %v", err)
+	}
+	expected := credentialprovider.DockerConfig{"registry.build01.ci.openshift.org": {Username: "push", Password: "secret"}}
+	if diff := cmp.Diff(expected, auths); diff != "" {
+		t.Errorf("got incorrect credentials: %s", diff)
+	}
+
+	if _, err := namespacePushSecret(context.Background(), client, "ci-op-2"); err == nil {
+		t.Error("expected an error for a namespace without a builder service account, got none")
+	}
+}
@@ -0,0 +1,63 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/kubernetes/pkg/credentialprovider"
+)
+
+// registryProbe returns an error when the registry cannot be pushed to
+type registryProbe func(ctx context.Context, registry string) error
+
+// pingRegistry probes the /v2/ endpoint of a registry, authenticating with
+// the credentials for it if we have any. Token-auth registries like quay.io
+// answer /v2/ with a Bearer challenge no matter the credentials, as those are
+// only exchanged for a token when pushing, so a challenge means the registry
+// is healthy.
+func pingRegistry(client *http.Client, credentials credentialprovider.DockerConfig) registryProbe {
+	return func(ctx context.Context, registry string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/", registry), nil)
+		if err != nil {
+			return fmt.Errorf("could not create request: %w", err)
+		}
+		entry, authenticated := credentials[registry]
+		if authenticated {
+			req.SetBasicAuth(entry.Username, entry.Password)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("registry is unreachable: %w", err)
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode >= http.StatusInternalServerError:
+			return fmt.Errorf("registry responded with %s", resp.Status)
+		case resp.StatusCode == http.StatusUnauthorized && isBearerChallenge(resp.Header.Get("WWW-Authenticate")):
+			return nil
+		case authenticated && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden):
+			return fmt.Errorf("registry rejected our credentials: %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// isBearerChallenge determines whether the registry asks for a token
+func isBearerChallenge(challenge string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(challenge)), "bearer ")
+}
+
+// defaultRegistryProbe pings registries with the credentials in the assembled registry config
+func defaultRegistryProbe(registryConfig []byte) registryProbe {
+	var config credentialprovider.DockerConfigJSON
+	if err := json.Unmarshal(registryConfig, &config); err != nil {
+		logrus.WithError(err).Warn("Could not read registry credentials for health checks.")
+	}
+	return pingRegistry(&http.Client{Timeout: 30 * time.Second}, config.Auths)
+}
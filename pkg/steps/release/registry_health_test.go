@@ -0,0 +1,81 @@
+package release
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/ci-tools/pkg/kubernetes/pkg/credentialprovider"
+)
+
+func TestPingRegistry(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		handler       http.HandlerFunc
+		credentials   bool
+		expectedError bool
+	}{
+		{
+			name:    "healthy registry",
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+		},
+		{
+			name:    "anonymous ping is challenged",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+		},
+		{
+			name: "credentials are accepted",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			},
+			credentials: true,
+		},
+		{
+			name:          "credentials are rejected",
+			handler:       func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+			credentials:   true,
+			expectedError: true,
+		},
+		{
+			name: "token-auth registry challenges the credentials",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="https://auth.example.com/token",service="registry.example.com"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			credentials: true,
+		},
+		{
+			name: "basic-auth registry rejects the credentials",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			credentials:   true,
+			expectedError: true,
+		},
+		{
+			name:          "registry is down",
+			handler:       func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			expectedError: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(testCase.handler)
+			defer server.Close()
+			registry := strings.TrimPrefix(server.URL, "https://")
+			credentials := credentialprovider.DockerConfig{}
+			if testCase.credentials {
+				credentials[registry] = credentialprovider.DockerConfigEntry{Username: "user", Password: "pass"}
+			}
+			err := pingRegistry(server.Client(), credentials)(context.Background(), registry)
+			if (err != nil) != testCase.expectedError {
+				t.Errorf("%s: expected error: %v, got: %v", testCase.name, testCase.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/retry"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// RevertPromotedTag re-points a single promoted tag to an image it referenced before,
+// so one bad component can be fixed without re-running a historical promotion. The
+// digest must be in the history of the tag, and the reason is recorded on the tag. The
+// tag stays pinned until the revert is cleared with ClearRevertedTag, or the tag is
+// promoted again by a job running on the cluster serving the image stream, which clears
+// the revert. Promotions from other clusters cannot clear it, so ClearRevertedTag has to
+// be used after them.
+func RevertPromotedTag(ctx context.Context, client ctrlruntimeclient.Client, tag api.ImageStreamTagReference, digest, reason string) error {
+	if reason == "" {
+		return errors.New("a reason is required to revert a promoted tag")
+	}
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		stream := &imagev1.ImageStream{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: tag.Namespace, Name: tag.Name}, stream); err != nil {
+			return err
+		}
+		if err := revertTag(stream, tag.Tag, digest, reason); err != nil {
+			return err
+		}
+		return client.Update(ctx, stream)
+	}); err != nil {
+		return fmt.Errorf("could not revert %s to %s: %w", tag.ISTagName(), digest, err)
+	}
+	return nil
+}
+
+// ClearRevertedTag undoes RevertPromotedTag by removing the pin and the reason from
+// the spec of the tag, so that it follows the images promoted to it again. The image
+// the tag was reverted to stays current until the next promotion.
+func ClearRevertedTag(ctx context.Context, client ctrlruntimeclient.Client, tag api.ImageStreamTagReference) error {
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		stream := &imagev1.ImageStream{}
+		if err := client.Get(ctx, ctrlruntimeclient.ObjectKey{Namespace: tag.Namespace, Name: tag.Name}, stream); err != nil {
+			return err
+		}
+		if err := clearRevert(stream, tag.Tag); err != nil {
+			return err
+		}
+		return client.Update(ctx, stream)
+	}); err != nil {
+		return fmt.Errorf("could not clear the revert of %s: %w", tag.ISTagName(), err)
+	}
+	return nil
+}
+
+// clearPromotedReverts clears the reverts of the tags that were just mirrored, as they
+// point at the newly promoted images again. Tags that are not reverted are left alone.
+func (s *promotionStep) clearPromotedReverts(ctx context.Context, tags map[string]api.ImageStreamTagReference, registry string, imageMirrorTarget map[string]string) error {
+	promoted := sets.NewString()
+	for _, dst := range imageMirrorTarget {
+		promoted.Insert(dst)
+	}
+	byStream := map[ctrlruntimeclient.ObjectKey]sets.String{}
+	for _, dst := range tags {
+		if !promoted.Has(fmt.Sprintf("%s/%s", registry, dst.ISTagName())) {
+			continue
+		}
+		key := ctrlruntimeclient.ObjectKey{Namespace: dst.Namespace, Name: dst.Name}
+		if byStream[key] == nil {
+			byStream[key] = sets.NewString()
+		}
+		byStream[key].Insert(dst.Tag)
+	}
+	for key, names := range byStream {
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			stream := &imagev1.ImageStream{}
+			if err := s.client.Get(ctx, key, stream); err != nil {
+				if kerrors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			}
+			var cleared []string
+			for _, name := range names.List() {
+				if clearRevert(stream, name) == nil {
+					cleared = append(cleared, name)
+				}
+			}
+			if len(cleared) == 0 {
+				return nil
+			}
+			logrus.Infof("Clearing the revert of promoted tags in imagestream %s: %s", key, strings.Join(cleared, ", "))
+			return s.client.Update(ctx, stream)
+		}); err != nil {
+			return fmt.Errorf("could not clear the reverts of promoted tags in imagestream %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// clearRevert removes the pin and the reason a revert put on the spec of the tag
+func clearRevert(stream *imagev1.ImageStream, tag string) error {
+	for i, t := range stream.Spec.Tags {
+		if t.Name != tag {
+			continue
+		}
+		if _, reverted := t.Annotations[api.ReleaseAnnotationRevertReason]; !reverted {
+			break
+		}
+		stream.Spec.Tags[i].From = nil
+		delete(stream.Spec.Tags[i].Annotations, api.ReleaseAnnotationRevertReason)
+		if len(stream.Spec.Tags[i].Annotations) == 0 {
+			stream.Spec.Tags[i].Annotations = nil
+		}
+		return nil
+	}
+	return fmt.Errorf("tag %s is not reverted", tag)
+}
+
+// revertTag points the spec of the tag at the image with the digest from its history
+func revertTag(stream *imagev1.ImageStream, tag, digest, reason string) error {
+	if !inTagHistory(stream, tag, digest) {
+		return fmt.Errorf("%s was never promoted to tag %s", digest, tag)
+	}
+	from := &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: fmt.Sprintf("%s@%s", stream.Name, digest)}
+	for i, t := range stream.Spec.Tags {
+		if t.Name != tag {
+			continue
+		}
+		stream.Spec.Tags[i].From = from
+		if t.Annotations == nil {
+			stream.Spec.Tags[i].Annotations = map[string]string{}
+		}
+		stream.Spec.Tags[i].Annotations[api.ReleaseAnnotationRevertReason] = reason
+		return nil
+	}
+	stream.Spec.Tags = append(stream.Spec.Tags, imagev1.TagReference{
+		Name:        tag,
+		From:        from,
+		Annotations: map[string]string{api.ReleaseAnnotationRevertReason: reason},
+	})
+	return nil
+}
+
+func inTagHistory(stream *imagev1.ImageStream, tag, digest string) bool {
+	for _, t := range stream.Status.Tags {
+		if t.Tag != tag {
+			continue
+		}
+		for _, item := range t.Items {
+			if item.Image == digest {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imageapi "github.com/openshift/api/image/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestRevertPromotedTag(t *testing.T) {
+	if err := imageapi.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("failed to register imagev1 scheme: %v", err)
+	}
+	stream := func(specTags ...imageapi.TagReference) *imageapi.ImageStream {
+		return &imageapi.ImageStream{
+			ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8"},
+			Spec:       imageapi.ImageStreamSpec{Tags: specTags},
+			Status: imageapi.ImageStreamStatus{Tags: []imageapi.NamedTagEventList{{
+				Tag:   "cli",
+				Items: []imageapi.TagEvent{{Image: "sha256:bad"}, {Image: "sha256:good"}},
+			}}},
+		}
+	}
+	tag := api.ImageStreamTagReference{Namespace: "ocp", Name: "4.8", Tag: "cli"}
+
+	var testCases = []struct {
+		name          string
+		stream        *imageapi.ImageStream
+		digest        string
+		reason        string
+		expected      []imageapi.TagReference
+		expectedError error
+	}{
+		{
+			name:   "tag only pushed to the stream is added to the spec",
+			stream: stream(),
+			digest: "sha256:good",
+			reason: "broke the installer",
+			expected: []imageapi.TagReference{{
+				Name:        "cli",
+				From:        &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "4.8@sha256:good"},
+				Annotations: map[string]string{"release.openshift.io/revert-reason": "broke the installer"},
+			}},
+		},
+		{
+			name:   "existing spec tag is re-pointed and keeps its annotations",
+			stream: stream(imageapi.TagReference{Name: "cli", Annotations: map[string]string{"other": "value"}}),
+			digest: "sha256:good",
+			reason: "broke the installer",
+			expected: []imageapi.TagReference{{
+				Name:        "cli",
+				From:        &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "4.8@sha256:good"},
+				Annotations: map[string]string{"other": "value", "release.openshift.io/revert-reason": "broke the installer"},
+			}},
+		},
+		{
+			name:          "digest not in the history of the tag is refused",
+			stream:        stream(),
+			digest:        "sha256:other",
+			reason:        "broke the installer",
+			expectedError: errors.New("could not revert ocp/4.8:cli to sha256:other: sha256:other was never promoted to tag cli"),
+		},
+		{
+			name:          "reason is required",
+			stream:        stream(),
+			digest:        "sha256:good",
+			expectedError: errors.New("a reason is required to revert a promoted tag"),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient(testCase.stream)
+			err := RevertPromotedTag(context.Background(), client, tag, testCase.digest, testCase.reason)
+			if diff := cmp.Diff([]error{testCase.expectedError}, []error{err}, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("got incorrect error: %v", diff)
+			}
+			if err != nil {
+				return
+			}
+			updated := &imageapi.ImageStream{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ocp", Name: "4.8"}, updated); err != nil {
+				t.Fatalf("could not get imagestream: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, updated.Spec.Tags); diff != "" {
+				t.Errorf("got incorrect spec tags: %v", diff)
+			}
+		})
+	}
+}
+
+func TestClearRevertedTag(t *testing.T) {
+	if err := imageapi.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("failed to register imagev1 scheme: %v", err)
+	}
+	tag := api.ImageStreamTagReference{Namespace: "ocp", Name: "4.8", Tag: "cli"}
+
+	var testCases = []struct {
+		name          string
+		specTags      []imageapi.TagReference
+		expected      []imageapi.TagReference
+		expectedError error
+	}{
+		{
+			name: "pin and reason are removed, other annotations are kept",
+			specTags: []imageapi.TagReference{{
+				Name:        "cli",
+				From:        &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "4.8@sha256:good"},
+				Annotations: map[string]string{"other": "value", "release.openshift.io/revert-reason": "broke the installer"},
+			}},
+			expected: []imageapi.TagReference{{Name: "cli", Annotations: map[string]string{"other": "value"}}},
+		},
+		{
+			name: "tag without other annotations is left without any",
+			specTags: []imageapi.TagReference{{
+				Name:        "cli",
+				From:        &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "4.8@sha256:good"},
+				Annotations: map[string]string{"release.openshift.io/revert-reason": "broke the installer"},
+			}},
+			expected: []imageapi.TagReference{{Name: "cli"}},
+		},
+		{
+			name:          "tag that is not reverted is refused",
+			specTags:      []imageapi.TagReference{{Name: "cli", From: &coreapi.ObjectReference{Kind: "ImageStreamImage", Name: "4.8@sha256:good"}}},
+			expectedError: errors.New("could not clear the revert of ocp/4.8:cli: tag cli is not reverted"),
+		},
+		{
+			name:          "tag without a spec is refused",
+			expectedError: errors.New("could not clear the revert of ocp/4.8:cli: tag cli is not reverted"),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakectrlruntimeclient.NewFakeClient(&imageapi.ImageStream{
+				ObjectMeta: meta.ObjectMeta{Namespace: "ocp", Name: "4.8"},
+				Spec:       imageapi.ImageStreamSpec{Tags: testCase.specTags},
+			})
+			err := ClearRevertedTag(context.Background(), client, tag)
+			if diff := cmp.Diff([]error{testCase.expectedError}, []error{err}, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("got incorrect error: %v", diff)
+			}
+			if err != nil {
+				return
+			}
+			updated := &imageapi.ImageStream{}
+			if err := client.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ocp", Name: "4.8"}, updated); err != nil {
+				t.Fatalf("could not get imagestream: %v", err)
+			}
+			if diff := cmp.Diff(testCase.expected, updated.Spec.Tags); diff != "" {
+				t.Errorf("got incorrect spec tags: %v", diff)
+			}
+		})
+	}
+}
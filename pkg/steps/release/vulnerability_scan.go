@@ -0,0 +1,131 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	coreapi "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// vulnerabilityReportTemplate makes the scanner list the ID of every vulnerability it found
+const vulnerabilityReportTemplate = `{{range .}}{{range .Vulnerabilities}}{{println .VulnerabilityID}}{{end}}{{end}}`
+
+// scanImages scans the images in the pipeline before they are promoted and fails when
+// any of them has more critical vulnerabilities than the configuration allows
+func (s *promotionStep) scanImages(ctx context.Context, imageMirrorTarget map[string]string, scan api.VulnerabilityScanConfiguration) error {
+	if len(imageMirrorTarget) == 0 {
+		return nil
+	}
+	sources := make([]string, 0, len(imageMirrorTarget))
+	for src := range imageMirrorTarget {
+		sources = append(sources, src)
+	}
+	logrus.Infof("Scanning %d images for critical vulnerabilities before promotion", len(sources))
+	pod, err := s.runPod(ctx, getVulnerabilityScanPod(sources, scan, s.jobSpec.Namespace()))
+	if err != nil {
+		if blocked := blockedImages(terminationMessage(pod), imageMirrorTarget); len(blocked) != 0 {
+			return fmt.Errorf("promotion blocked by critical vulnerabilities (at most %d allowed): %s", scan.MaxCritical, strings.Join(blocked, "; "))
+		}
+		return fmt.Errorf("unable to scan images for vulnerabilities: %w", err)
+	}
+	return nil
+}
+
+// blockedImages formats the images the scan pod reported, one per line as the image and
+// the number of critical vulnerabilities found, or "failed" when it could not be scanned
+func blockedImages(message string, imageMirrorTarget map[string]string) []string {
+	var blocked []string
+	for _, line := range strings.Split(strings.TrimSpace(message), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		dst, known := imageMirrorTarget[fields[0]]
+		if !known {
+			continue
+		}
+		if fields[1] == "failed" {
+			blocked = append(blocked, fmt.Sprintf("%s could not be scanned", dst))
+			continue
+		}
+		blocked = append(blocked, fmt.Sprintf("%s has %s critical vulnerabilities", dst, fields[1]))
+	}
+	sort.Strings(blocked)
+	return blocked
+}
+
+// getVulnerabilityScanPod returns a pod that scans every image and reports the ones over
+// the threshold in its termination message
+func getVulnerabilityScanPod(sources []string, scan api.VulnerabilityScanConfiguration, namespace string) *coreapi.Pod {
+	sort.Strings(sources)
+	var images []string
+	for _, source := range sources {
+		images = append(images, shellQuote(source))
+	}
+	allowlist := ": > /tmp/allowlist"
+	if len(scan.Allowlist) != 0 {
+		var ids []string
+		for _, id := range scan.Allowlist {
+			ids = append(ids, shellQuote(id))
+		}
+		allowlist = fmt.Sprintf(`printf '%%s\n' %s > /tmp/allowlist`, strings.Join(ids, " "))
+	}
+	script := fmt.Sprintf(`%s
+failed=0
+for image in %s; do
+  if ! trivy image --quiet --severity=CRITICAL --ignorefile=/tmp/allowlist --format=template --template=%s --output=/tmp/report "$image"; then
+    echo "$image failed" | tee -a /dev/termination-log
+    failed=1
+    continue
+  fi
+  count=$(sort -u /tmp/report | grep -c .)
+  if [ "$count" -gt %d ]; then
+    echo "$image $count" | tee -a /dev/termination-log
+    failed=1
+  fi
+done
+exit $failed`, allowlist, strings.Join(images, " "), shellQuote(vulnerabilityReportTemplate), scan.MaxCritical)
+	return &coreapi.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "promotion-vulnerability-scan",
+			Namespace: namespace,
+		},
+		Spec: coreapi.PodSpec{
+			RestartPolicy: coreapi.RestartPolicyNever,
+			Containers: []coreapi.Container{
+				{
+					Name:    "vulnerability-scan",
+					Image:   promotionPodImage(scan.Image),
+					Command: []string{"/bin/sh", "-c"},
+					Args:    []string{script},
+					Env:     []coreapi.EnvVar{{Name: "DOCKER_CONFIG", Value: api.RegistryPushCredentialsCICentralSecretMountPath}},
+					VolumeMounts: []coreapi.VolumeMount{
+						{
+							Name:      "push-secret",
+							MountPath: api.RegistryPushCredentialsCICentralSecretMountPath,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Volumes: []coreapi.Volume{
+				{
+					Name: "push-secret",
+					VolumeSource: coreapi.VolumeSource{
+						Secret: &coreapi.SecretVolumeSource{
+							SecretName: promotionRegistryConfigSecret,
+							Items:      []coreapi.KeyToPath{{Key: coreapi.DockerConfigJsonKey, Path: "config.json"}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
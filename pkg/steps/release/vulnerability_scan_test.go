@@ -0,0 +1,47 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"github.com/openshift/ci-tools/pkg/testhelper"
+)
+
+func TestBlockedImages(t *testing.T) {
+	targets := map[string]string{
+		"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:aaa": "quay.io/ocp/4.8:a",
+		"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:bbb": "quay.io/ocp/4.8:b",
+	}
+	message := "registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:bbb failed\nregistry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:aaa 3\nFATAL something else\n"
+	expected := []string{
+		"quay.io/ocp/4.8:a has 3 critical vulnerabilities",
+		"quay.io/ocp/4.8:b could not be scanned",
+	}
+	if diff := cmp.Diff(expected, blockedImages(message, targets)); diff != "" {
+		t.Errorf("got incorrect blocked images: %v", diff)
+	}
+}
+
+func TestGetVulnerabilityScanPod(t *testing.T) {
+	var testCases = []struct {
+		name string
+		scan api.VulnerabilityScanConfiguration
+	}{
+		{
+			name: "no allowlist",
+		},
+		{
+			name: "allowlist and threshold",
+			scan: api.VulnerabilityScanConfiguration{MaxCritical: 2, Allowlist: []string{"CVE-2021-3449", "CVE-2021-3450"}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			sources := []string{"registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:bbb", "registry.svc.ci.openshift.org/ci-op-1/pipeline@sha256:aaa"}
+			testCase.scan.Image = api.ImageStreamTagReference{Namespace: "ci", Name: "trivy", Tag: "v0.20"}
+			testhelper.CompareWithFixture(t, getVulnerabilityScanPod(sources, testCase.scan, "ci-op-zyvwvffx"))
+		})
+	}
+}
@@ -3,6 +3,8 @@ package validation
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -350,6 +352,254 @@ func validatePromotionConfiguration(fieldRoot string, input api.PromotionConfigu
 	if len(input.Name) != 0 && len(input.Tag) != 0 {
 		validationErrors = append(validationErrors, fmt.Errorf("%s: both name and tag defined", fieldRoot))
 	}
+
+	for _, dst := range sets.StringKeySet(input.AdditionalImages).List() {
+		if stream, tag := api.SplitAdditionalImageSource(input.AdditionalImages[dst]); len(stream) == 0 || len(tag) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.additional_images.%s: invalid source %q, must be a tag or stream:tag", fieldRoot, dst, input.AdditionalImages[dst]))
+		}
+	}
+
+	for i, pattern := range input.ExcludedImages {
+		if _, err := api.MatchesExcludedImage(pattern, ""); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.excluded_images[%d]: invalid pattern %q: %v", fieldRoot, i, pattern, err))
+		}
+	}
+
+	if input.SkipIfOnlyChanged != "" {
+		if _, err := regexp.Compile(input.SkipIfOnlyChanged); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.skip_if_only_changed: invalid regular expression: %v", fieldRoot, err))
+		}
+	}
+
+	if input.VulnerabilityScan != nil {
+		validationErrors = append(validationErrors, validatePromotionPodImage(fmt.Sprintf("%s.vulnerability_scan.image", fieldRoot), input.VulnerabilityScan.Image)...)
+		if input.VulnerabilityScan.MaxCritical < 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.vulnerability_scan.max_critical: must not be negative", fieldRoot))
+		}
+		for i, id := range input.VulnerabilityScan.Allowlist {
+			if strings.TrimSpace(id) == "" || strings.ContainsAny(id, " \t\n") {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.vulnerability_scan.allowlist[%d]: invalid vulnerability ID %q", fieldRoot, i, id))
+			}
+		}
+	}
+
+	seenAliases := sets.NewString()
+	for i, alias := range input.Aliases {
+		switch {
+		case alias != api.PromotionAliasDate && alias != api.PromotionAliasCommit:
+			validationErrors = append(validationErrors, fmt.Errorf("%s.aliases[%d]: unknown alias %q, expected %q or %q", fieldRoot, i, alias, api.PromotionAliasDate, api.PromotionAliasCommit))
+		case seenAliases.Has(string(alias)):
+			validationErrors = append(validationErrors, fmt.Errorf("%s.aliases[%d]: duplicate alias %q", fieldRoot, i, alias))
+		}
+		seenAliases.Insert(string(alias))
+	}
+
+	if input.Pruning != nil {
+		switch {
+		case input.Pruning.KeepLast < 0:
+			validationErrors = append(validationErrors, fmt.Errorf("%s.pruning.keep_last: must not be negative", fieldRoot))
+		case input.Pruning.MaxAge != nil && input.Pruning.MaxAge.Duration <= 0:
+			validationErrors = append(validationErrors, fmt.Errorf("%s.pruning.max_age: must be positive", fieldRoot))
+		case input.Pruning.KeepLast == 0 && input.Pruning.MaxAge == nil:
+			validationErrors = append(validationErrors, fmt.Errorf("%s.pruning: keep_last or max_age must be set", fieldRoot))
+		}
+	}
+
+	if input.Mirror != nil {
+		if input.Mirror.ShardSize < 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.mirror.shard_size: must not be negative", fieldRoot))
+		}
+		if input.Mirror.MaxPerRegistry < 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.mirror.max_per_registry: must not be negative", fieldRoot))
+		}
+		if input.Mirror.Attempts < 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.mirror.attempts: must not be negative", fieldRoot))
+		}
+		if input.Mirror.RetryDelay != nil && input.Mirror.RetryDelay.Duration < 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.mirror.retry_delay: must not be negative", fieldRoot))
+		}
+	}
+
+	seenRegistries := sets.NewString()
+	for i, registry := range input.AdditionalRegistries {
+		switch {
+		case registry == "":
+			validationErrors = append(validationErrors, fmt.Errorf("%s.additional_registries[%d]: must not be empty", fieldRoot, i))
+		case strings.Contains(registry, "/"):
+			validationErrors = append(validationErrors, fmt.Errorf("%s.additional_registries[%d]: %q must be a registry domain without a path", fieldRoot, i, registry))
+		case seenRegistries.Has(registry):
+			validationErrors = append(validationErrors, fmt.Errorf("%s.additional_registries[%d]: duplicate registry %q", fieldRoot, i, registry))
+		}
+		seenRegistries.Insert(registry)
+	}
+
+	if input.Timeout != nil && input.Timeout.Duration <= 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.timeout: must be positive", fieldRoot))
+	}
+
+	if input.QuayProvisioning != nil {
+		validationErrors = append(validationErrors, validateQuayProvisioning(fmt.Sprintf("%s.quay_provisioning", fieldRoot), *input.QuayProvisioning)...)
+	}
+
+	if input.TagViaAPI && len(input.RegistryOverride) != 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: tag_via_api cannot be used with registry_override", fieldRoot))
+	}
+
+	seenSecrets := sets.NewString()
+	for i, secret := range input.PushSecrets {
+		if len(secret.Namespace) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.push_secrets[%d]: no namespace defined", fieldRoot, i))
+		} else if secret.Namespace != api.PromotionCredentialsNamespace {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.push_secrets[%d].namespace: must be %s", fieldRoot, i, api.PromotionCredentialsNamespace))
+		}
+		if len(secret.Name) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.push_secrets[%d]: no name defined", fieldRoot, i))
+		}
+		key := secret.Namespace + "/" + secret.Name
+		if seenSecrets.Has(key) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.push_secrets[%d]: duplicate secret %s", fieldRoot, i, key))
+		}
+		seenSecrets.Insert(key)
+	}
+
+	if input.Archive != nil {
+		if len(input.Archive.Namespace) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.archive: no namespace defined", fieldRoot))
+		}
+		if input.Archive.RetentionDays < 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.archive.retention_days: must not be negative", fieldRoot))
+		}
+	}
+
+	if input.Provenance != nil {
+		validationErrors = append(validationErrors, validateProvenance(fmt.Sprintf("%s.provenance", fieldRoot), *input.Provenance)...)
+	}
+
+	if input.SignatureVerification != nil {
+		validationErrors = append(validationErrors, validateSignatureVerification(fmt.Sprintf("%s.signature_verification", fieldRoot), *input.SignatureVerification)...)
+	}
+
+	if input.PayloadEligibility != nil {
+		validationErrors = append(validationErrors, validatePayloadEligibility(fmt.Sprintf("%s.payload_eligibility", fieldRoot), input)...)
+	}
+	return validationErrors
+}
+
+func validatePayloadEligibility(fieldRoot string, input api.PromotionConfiguration) []error {
+	var validationErrors []error
+
+	if len(input.RegistryOverride) != 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: cannot be set together with registry_override, as only image streams on the cluster can be annotated", fieldRoot))
+	}
+	if len(input.PayloadEligibility.Gates) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: no gates defined", fieldRoot))
+	}
+	for i, gate := range input.PayloadEligibility.Gates {
+		switch gate {
+		case api.PayloadEligibilityGateSignatureVerification:
+			if input.SignatureVerification == nil {
+				validationErrors = append(validationErrors, fmt.Errorf("%s.gates[%d]: %s requires signature_verification to be configured", fieldRoot, i, gate))
+			}
+		default:
+			validationErrors = append(validationErrors, fmt.Errorf("%s.gates[%d]: must be one of %s", fieldRoot, i, api.PayloadEligibilityGateSignatureVerification))
+		}
+	}
+	return validationErrors
+}
+
+func validateQuayProvisioning(fieldRoot string, input api.QuayProvisioningConfiguration) []error {
+	var validationErrors []error
+
+	if len(input.TokenSecret.Namespace) == 0 || len(input.TokenSecret.Name) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.token_secret: namespace and name are required", fieldRoot))
+	} else if input.TokenSecret.Namespace != api.PromotionCredentialsNamespace {
+		validationErrors = append(validationErrors, fmt.Errorf("%s.token_secret.namespace: must be %s", fieldRoot, api.PromotionCredentialsNamespace))
+	}
+	switch input.Visibility {
+	case "", api.QuayVisibilityPublic, api.QuayVisibilityPrivate:
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("%s.visibility: must be one of %s, %s", fieldRoot, api.QuayVisibilityPublic, api.QuayVisibilityPrivate))
+	}
+	seenTeams := sets.NewString()
+	for i, team := range input.Teams {
+		if len(team.Name) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.teams[%d]: no name defined", fieldRoot, i))
+		} else if seenTeams.Has(team.Name) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.teams[%d]: duplicate team %s", fieldRoot, i, team.Name))
+		}
+		seenTeams.Insert(team.Name)
+		switch team.Role {
+		case "read", "write", "admin":
+		default:
+			validationErrors = append(validationErrors, fmt.Errorf("%s.teams[%d].role: must be one of read, write, admin", fieldRoot, i))
+		}
+	}
+	return validationErrors
+}
+
+func validateProvenance(fieldRoot string, input api.ProvenanceConfiguration) []error {
+	var validationErrors []error
+
+	validationErrors = append(validationErrors, validatePromotionPodImage(fmt.Sprintf("%s.image", fieldRoot), input.Image)...)
+	if len(input.Key) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: no key defined", fieldRoot))
+	}
+	validationErrors = append(validationErrors, validateCredentials(fieldRoot, input.Credentials)...)
+	for i, credential := range input.Credentials {
+		if len(credential.Namespace) != 0 && credential.Namespace != api.PromotionCredentialsNamespace {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.credentials[%d].namespace: must be %s", fieldRoot, i, api.PromotionCredentialsNamespace))
+		}
+		if filepath.IsAbs(credential.MountPath) && mountPathsOverlap(credential.MountPath, api.RegistryPushCredentialsCICentralSecretMountPath) {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.credentials[%d] mounts at %s, which overlaps with the registry credentials (%s)", fieldRoot, i, credential.MountPath, api.RegistryPushCredentialsCICentralSecretMountPath))
+		}
+	}
+	for name := range input.Env {
+		if name == "DOCKER_CONFIG" || name == "PROVENANCE" {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.env.%s: cannot be overridden", fieldRoot, name))
+		}
+	}
+	return validationErrors
+}
+
+// mountPathsOverlap determines whether one of the absolute paths is under the other
+func mountPathsOverlap(a, b string) bool {
+	for _, paths := range [][2]string{{a, b}, {b, a}} {
+		if relPath, err := filepath.Rel(paths[0], paths[1]); err == nil && !strings.Contains(relPath, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePromotionPodImage validates the image a pod run during promotion uses
+func validatePromotionPodImage(fieldRoot string, input api.ImageStreamTagReference) []error {
+	if len(input.Namespace) == 0 || len(input.Name) == 0 || len(input.Tag) == 0 {
+		return []error{fmt.Errorf("%s: namespace, name and tag are required", fieldRoot)}
+	}
+	return nil
+}
+
+func validateSignatureVerification(fieldRoot string, input api.SignatureVerificationConfiguration) []error {
+	var validationErrors []error
+
+	validationErrors = append(validationErrors, validatePromotionPodImage(fmt.Sprintf("%s.image", fieldRoot), input.Image)...)
+	if len(input.Identities) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("%s: no identities defined", fieldRoot))
+	}
+	for i, identity := range input.Identities {
+		if len(identity.Identity) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.identities[%d]: no identity defined", fieldRoot, i))
+		}
+		if len(identity.Issuer) == 0 {
+			validationErrors = append(validationErrors, fmt.Errorf("%s.identities[%d]: no issuer defined", fieldRoot, i))
+		}
+	}
+
+	switch input.Policy {
+	case "", api.VerificationPolicyEnforce, api.VerificationPolicyWarn:
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("%s.policy: must be one of %s, %s", fieldRoot, api.VerificationPolicyEnforce, api.VerificationPolicyWarn))
+	}
 	return validationErrors
 }
 
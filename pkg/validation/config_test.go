@@ -4,9 +4,11 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/utils/diff"
 	utilpointer "k8s.io/utils/pointer"
 
@@ -274,6 +276,210 @@ func TestValidatePromotion(t *testing.T) {
 			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Tag: "baz"},
 			expected: []error{errors.New("promotion: both name and tag defined")},
 		},
+		{
+			name: "valid signature verification",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", SignatureVerification: &api.SignatureVerificationConfiguration{
+				Image:      api.ImageStreamTagReference{Namespace: "ci", Name: "cosign", Tag: "v2"},
+				Identities: []api.SignerIdentity{{Identity: "release@openshift.io", Issuer: "https://accounts.google.com"}},
+				Policy:     api.VerificationPolicyWarn,
+			}},
+			expected: nil,
+		},
+		{
+			name: "invalid signature verification yields errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", SignatureVerification: &api.SignatureVerificationConfiguration{
+				Image:      api.ImageStreamTagReference{Name: "cosign", Tag: "v2"},
+				Identities: []api.SignerIdentity{{Identity: "release@openshift.io"}},
+				Policy:     "maybe",
+			}},
+			expected: []error{
+				errors.New("promotion.signature_verification.image: namespace, name and tag are required"),
+				errors.New("promotion.signature_verification.identities[0]: no issuer defined"),
+				errors.New("promotion.signature_verification.policy: must be one of enforce, warn"),
+			},
+		},
+		{
+			name:     "invalid vulnerability scan yields errors",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", VulnerabilityScan: &api.VulnerabilityScanConfiguration{MaxCritical: -1, Allowlist: []string{"CVE-2021-3449", "", "CVE-1 CVE-2"}}},
+			expected: []error{errors.New("promotion.vulnerability_scan.image: namespace, name and tag are required"), errors.New("promotion.vulnerability_scan.max_critical: must not be negative"), errors.New(`promotion.vulnerability_scan.allowlist[1]: invalid vulnerability ID ""`), errors.New(`promotion.vulnerability_scan.allowlist[2]: invalid vulnerability ID "CVE-1 CVE-2"`)},
+		},
+		{
+			name:  "valid vulnerability scan",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", VulnerabilityScan: &api.VulnerabilityScanConfiguration{Image: api.ImageStreamTagReference{Namespace: "ci", Name: "trivy", Tag: "v0.20"}, MaxCritical: 1}},
+		},
+		{
+			name:     "invalid aliases yield errors",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Aliases: []api.PromotionAlias{"date", "branch", "date"}},
+			expected: []error{errors.New(`promotion.aliases[1]: unknown alias "branch", expected "date" or "commit"`), errors.New(`promotion.aliases[2]: duplicate alias "date"`)},
+		},
+		{
+			name:     "empty pruning policy yields an error",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Pruning: &api.PruningConfiguration{}},
+			expected: []error{errors.New("promotion.pruning: keep_last or max_age must be set")},
+		},
+		{
+			name:     "invalid pruning policy yields an error",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Pruning: &api.PruningConfiguration{KeepLast: 1, MaxAge: &prowv1.Duration{}}},
+			expected: []error{errors.New("promotion.pruning.max_age: must be positive")},
+		},
+		{
+			name:     "negative mirror shard size yields an error",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Mirror: &api.MirrorConfiguration{ShardSize: -1}},
+			expected: []error{errors.New("promotion.mirror.shard_size: must not be negative")},
+		},
+		{
+			name:  "negative mirror tuning yields errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Mirror: &api.MirrorConfiguration{MaxPerRegistry: -1, Attempts: -1, RetryDelay: &prowv1.Duration{Duration: -time.Second}}},
+			expected: []error{
+				errors.New("promotion.mirror.max_per_registry: must not be negative"),
+				errors.New("promotion.mirror.attempts: must not be negative"),
+				errors.New("promotion.mirror.retry_delay: must not be negative"),
+			},
+		},
+		{
+			name:     "invalid additional registries yield errors",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", AdditionalRegistries: []string{"quay.io", "", "quay.io/openshift", "quay.io"}},
+			expected: []error{errors.New("promotion.additional_registries[1]: must not be empty"), errors.New(`promotion.additional_registries[2]: "quay.io/openshift" must be a registry domain without a path`), errors.New(`promotion.additional_registries[3]: duplicate registry "quay.io"`)},
+		},
+		{
+			name:     "additional images from other streams are valid",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", AdditionalImages: map[string]string{"cli": "stable:cli", "src": "src"}},
+			expected: nil,
+		},
+		{
+			name:     "invalid additional image sources yield errors",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", AdditionalImages: map[string]string{"cli": "stable:", "base": ":base", "empty": ""}},
+			expected: []error{errors.New(`promotion.additional_images.base: invalid source ":base", must be a tag or stream:tag`), errors.New(`promotion.additional_images.cli: invalid source "stable:", must be a tag or stream:tag`), errors.New(`promotion.additional_images.empty: invalid source "", must be a tag or stream:tag`)},
+		},
+		{
+			name:     "invalid excluded image patterns yield errors",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", ExcludedImages: []string{"foo", "tests-*", "tests-[", "/(/"}},
+			expected: []error{errors.New(`promotion.excluded_images[2]: invalid pattern "tests-[": syntax error in pattern`), errors.New("promotion.excluded_images[3]: invalid pattern \"/(/\": error parsing regexp: missing closing ): `(`")},
+		},
+		{
+			name:     "non-positive timeout yields an error",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Timeout: &prowv1.Duration{}},
+			expected: []error{errors.New("promotion.timeout: must be positive")},
+		},
+		{
+			name: "invalid quay provisioning yields errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", QuayProvisioning: &api.QuayProvisioningConfiguration{
+				Visibility: "internal",
+				Teams:      []api.QuayTeamPermission{{Name: "owners", Role: "write"}, {Name: "owners", Role: "owner"}, {Role: "read"}},
+			}},
+			expected: []error{
+				errors.New("promotion.quay_provisioning.token_secret: namespace and name are required"),
+				errors.New("promotion.quay_provisioning.visibility: must be one of public, private"),
+				errors.New("promotion.quay_provisioning.teams[1]: duplicate team owners"),
+				errors.New("promotion.quay_provisioning.teams[1].role: must be one of read, write, admin"),
+				errors.New("promotion.quay_provisioning.teams[2]: no name defined"),
+			},
+		},
+		{
+			name: "quay token secret outside of the credentials namespace yields an error",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", QuayProvisioning: &api.QuayProvisioningConfiguration{
+				TokenSecret: api.PushSecretReference{Namespace: "ci", Name: "quay-admin"},
+			}},
+			expected: []error{errors.New("promotion.quay_provisioning.token_secret.namespace: must be test-credentials")},
+		},
+		{
+			name:     "tagging via the API with a registry override yields an error",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", RegistryOverride: "quay.io", TagViaAPI: true},
+			expected: []error{errors.New("promotion: tag_via_api cannot be used with registry_override")},
+		},
+		{
+			name:  "invalid push secrets yield errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", PushSecrets: []api.PushSecretReference{{Namespace: "test-credentials", Name: "quay-push"}, {Name: "other"}, {Namespace: "test-credentials", Name: "quay-push"}}},
+			expected: []error{
+				errors.New("promotion.push_secrets[1]: no namespace defined"),
+				errors.New("promotion.push_secrets[2]: duplicate secret test-credentials/quay-push"),
+			},
+		},
+		{
+			name:     "push secrets outside of the credentials namespace yield an error",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", PushSecrets: []api.PushSecretReference{{Namespace: "ci", Name: "registry-push-credentials-ci-central"}}},
+			expected: []error{errors.New("promotion.push_secrets[0].namespace: must be test-credentials")},
+		},
+		{
+			name:  "provenance without an image or a key yields errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Provenance: &api.ProvenanceConfiguration{}},
+			expected: []error{
+				errors.New("promotion.provenance.image: namespace, name and tag are required"),
+				errors.New("promotion.provenance: no key defined"),
+			},
+		},
+		{
+			name: "valid provenance with credentials",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Provenance: &api.ProvenanceConfiguration{
+				Image:       api.ImageStreamTagReference{Namespace: "ci", Name: "cosign", Tag: "v2"},
+				Key:         "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k",
+				Credentials: []api.CredentialReference{{Namespace: "test-credentials", Name: "kms", MountPath: "/var/run/kms"}},
+				Env:         map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": "/var/run/kms/credentials.json"},
+			}},
+		},
+		{
+			name: "invalid provenance credentials yield errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", Provenance: &api.ProvenanceConfiguration{
+				Image: api.ImageStreamTagReference{Namespace: "ci", Name: "cosign", Tag: "v2"},
+				Key:   "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k",
+				Credentials: []api.CredentialReference{
+					{MountPath: "/var/run/a"},
+					{Namespace: "test-credentials", Name: "kms", MountPath: "/etc/push-secret/kms"},
+					{Namespace: "ci", Name: "kms", MountPath: "/var/run/kms"},
+					{Namespace: "test-credentials", Name: "other", MountPath: "/var/run/kms/other"},
+				},
+				Env: map[string]string{"DOCKER_CONFIG": "/tmp"},
+			}},
+			expected: []error{
+				errors.New("promotion.provenance.credentials[0].name cannot be empty"),
+				errors.New("promotion.provenance.credentials[0].namespace cannot be empty"),
+				errors.New("promotion.provenance.credentials[3] mounts at /var/run/kms/other, which is under credentials[2] (/var/run/kms)"),
+				errors.New("promotion.provenance.credentials[1] mounts at /etc/push-secret/kms, which overlaps with the registry credentials (/etc/push-secret)"),
+				errors.New("promotion.provenance.credentials[2].namespace: must be test-credentials"),
+				errors.New("promotion.provenance.env.DOCKER_CONFIG: cannot be overridden"),
+			},
+		},
+		{
+			name:     "invalid archive yields errors",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", Archive: &api.PromotionArchiveConfiguration{RetentionDays: -1}},
+			expected: []error{errors.New("promotion.archive: no namespace defined"), errors.New("promotion.archive.retention_days: must not be negative")},
+		},
+		{
+			name:     "invalid skip_if_only_changed yields errors",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", SkipIfOnlyChanged: "docs/("},
+			expected: []error{errors.New("promotion.skip_if_only_changed: invalid regular expression: error parsing regexp: missing closing ): `docs/(`")},
+		},
+		{
+			name:  "signature verification without identities yields errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", SignatureVerification: &api.SignatureVerificationConfiguration{}},
+			expected: []error{
+				errors.New("promotion.signature_verification.image: namespace, name and tag are required"),
+				errors.New("promotion.signature_verification: no identities defined"),
+			},
+		},
+		{
+			name: "valid payload eligibility",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar",
+				SignatureVerification: &api.SignatureVerificationConfiguration{Image: api.ImageStreamTagReference{Namespace: "ci", Name: "cosign", Tag: "v2"}, Identities: []api.SignerIdentity{{Identity: "release@openshift.io", Issuer: "https://accounts.google.com"}}, Policy: api.VerificationPolicyWarn},
+				PayloadEligibility:    &api.PayloadEligibilityConfiguration{Gates: []api.PayloadEligibilityGate{api.PayloadEligibilityGateSignatureVerification}},
+			},
+		},
+		{
+			name:     "payload eligibility without gates yields errors",
+			input:    api.PromotionConfiguration{Namespace: "foo", Name: "bar", PayloadEligibility: &api.PayloadEligibilityConfiguration{}},
+			expected: []error{errors.New("promotion.payload_eligibility: no gates defined")},
+		},
+		{
+			name: "payload eligibility with an unconfigured or unknown gate and a registry override yields errors",
+			input: api.PromotionConfiguration{Namespace: "foo", Name: "bar", RegistryOverride: "quay.io",
+				PayloadEligibility: &api.PayloadEligibilityConfiguration{Gates: []api.PayloadEligibilityGate{api.PayloadEligibilityGateSignatureVerification, "tests"}},
+			},
+			expected: []error{
+				errors.New("promotion.payload_eligibility: cannot be set together with registry_override, as only image streams on the cluster can be annotated"),
+				errors.New("promotion.payload_eligibility.gates[0]: signature_verification requires signature_verification to be configured"),
+				errors.New("promotion.payload_eligibility.gates[1]: must be one of signature_verification"),
+			},
+		},
 	}
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
@@ -119,6 +119,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                  destination_dir: ' '\n" +
 	"                  # SourcePath is a file or directory in the source image to copy from.\n" +
 	"                  source_path: ' '\n" +
+	"      # Promote, when set to false, excludes the image from promotion\n" +
+	"      # as if it were listed in excluded_images. The image is still\n" +
+	"      # built and can be used in tests.\n" +
+	"      promote: false\n" +
 	"      to: ' '\n" +
 	"# Operator describes the operator bundle(s) that is built by the project\n" +
 	"operator:\n" +
@@ -152,15 +156,50 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"    # images will be taken from the pipeline image stream. The\n" +
 	"    # key is the name to promote as and the value is the source\n" +
 	"    # name. If you specify a tag that does not exist as the source\n" +
-	"    # the destination tag will not be created.\n" +
+	"    # the destination tag will not be created. A source in the\n" +
+	"    # stream:tag format, like `stable:cli`, is taken from that\n" +
+	"    # image stream in the test namespace instead.\n" +
 	"    additional_images:\n" +
 	"        \"\": \"\"\n" +
+	"    # AdditionalRegistries are registries the promoted images\n" +
+	"    # are mirrored to besides the one they are promoted to, for\n" +
+	"    # example quay.io mirrors. Credentials for each registry are\n" +
+	"    # read from the push secret. A failure to push to one of them\n" +
+	"    # does not prevent promotion to the others.\n" +
+	"    additional_registries:\n" +
+	"        - \"\"\n" +
+	"    # Aliases are additional tags every promoted image gets in\n" +
+	"    # its destination image stream, so consumers can pin to an\n" +
+	"    # exact promoted build. The alias of a tag is the tag with\n" +
+	"    # the date of the promotion or the promoted commit appended.\n" +
+	"    aliases:\n" +
+	"        - \"\"\n" +
+	"    # Archive, when set, additionally mirrors every promoted image\n" +
+	"    # into a long-term archive that survives pruning of the\n" +
+	"    # destination image streams.\n" +
+	"    archive:\n" +
+	"        # Namespace is the namespace in the archive registry that\n" +
+	"        # images are mirrored to.\n" +
+	"        namespace: ' '\n" +
+	"        # Registry is the registry hosting the archive. Defaults\n" +
+	"        # to the registry images are promoted to.\n" +
+	"        registry: ' '\n" +
 	"    # ExcludedImages are image names that will not be promoted.\n" +
 	"    # Exclusions are made before additional_images are included.\n" +
 	"    # Use exclusions when you want to build images for testing\n" +
-	"    # but not promote them afterwards.\n" +
+	"    # but not promote them afterwards. Besides exact names, an\n" +
+	"    # exclusion can be a glob like `tests-*` or a regular\n" +
+	"    # expression wrapped in slashes like `/^tests-.*$/`.\n" +
 	"    excluded_images:\n" +
 	"        - \"\"\n" +
+	"    # Mirror tunes how images are mirrored to the registries.\n" +
+	"    mirror:\n" +
+	"        # ContinueOnError keeps mirroring the remaining images when\n" +
+	"        # one of them fails. Defaults to true.\n" +
+	"        continue_on_error: false\n" +
+	"        # RetryDelay is how long to wait before the first retry. The\n" +
+	"        # delay doubles for every following retry. Defaults to 30s.\n" +
+	"        retry_delay: 0s\n" +
 	"    # Name is an optional image stream name to use that\n" +
 	"    # contains all component tags. If specified, tag is\n" +
 	"    # ignored.\n" +
@@ -168,14 +207,145 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"    # Namespace identifies the namespace to which the built\n" +
 	"    # artifacts will be published to.\n" +
 	"    namespace: ' '\n" +
+	"    # PayloadEligibility, when set, annotates every promoted tag in\n" +
+	"    # the destination image stream with whether the release controller\n" +
+	"    # may include it in release payloads, based on the results of the\n" +
+	"    # configured gates. Only image streams on the cluster the job runs\n" +
+	"    # on can be annotated, so it cannot be used with registry_override.\n" +
+	"    payload_eligibility:\n" +
+	"        # Gates are the checks run during promotion that decide whether\n" +
+	"        # a promoted image may be included in release payloads. Images\n" +
+	"        # that fail any of them are annotated as experimental, all others\n" +
+	"        # as eligible.\n" +
+	"        gates:\n" +
+	"            - \"\"\n" +
+	"    # Provenance, when set, attaches a SLSA provenance attestation\n" +
+	"    # describing the job that built them to every promoted image.\n" +
+	"    provenance:\n" +
+	"        # Credentials are secrets mounted for cosign, e.g. the cloud\n" +
+	"        # credentials needed to sign with a KMS key. They must exist in\n" +
+	"        # the test-credentials namespace.\n" +
+	"        credentials:\n" +
+	"            - # MountPath is where the secret should be mounted.\n" +
+	"              mount_path: ' '\n" +
+	"              # Names is which source secret to mount.\n" +
+	"              name: ' '\n" +
+	"              # Namespace is where the source secret exists.\n" +
+	"              namespace: ' '\n" +
+	"        # Env are environment variables set for cosign, e.g.\n" +
+	"        # GOOGLE_APPLICATION_CREDENTIALS pointing at a file in one of\n" +
+	"        # the mounted credentials.\n" +
+	"        env:\n" +
+	"            \"\": \"\"\n" +
+	"        # Image is the image stream tag of an image providing the\n" +
+	"        # cosign binary that attaches the attestations.\n" +
+	"        image:\n" +
+	"            # As is an optional string to use as the intermediate name for this reference.\n" +
+	"            as: ' '\n" +
+	"            name: ' '\n" +
+	"            namespace: ' '\n" +
+	"            tag: ' '\n" +
+	"        # Key is the cosign reference of the key the attestations are\n" +
+	"        # signed with, e.g. a KMS URI.\n" +
+	"        key: ' '\n" +
+	"    # Pruning, when set, deletes old aliases from the destination\n" +
+	"    # image streams after promotion. Aliases are only pruned when\n" +
+	"    # the job runs on the cluster serving the registry; otherwise,\n" +
+	"    # promoted-alias-pruner has to be run against that cluster.\n" +
+	"    pruning:\n" +
+	"        # MaxAge is the age after which aliases are deleted.\n" +
+	"        max_age: 0s\n" +
+	"    # PushSecrets are dockerconfigjson secrets holding the credentials\n" +
+	"    # for registries the central push secret does not cover, like\n" +
+	"    # team-owned external registries. They are merged with the central\n" +
+	"    # push secret into the registry config of the promotion pods.\n" +
+	"    # They must exist in the test-credentials namespace.\n" +
+	"    push_secrets:\n" +
+	"        - # Name is the name of the secret.\n" +
+	"          name: ' '\n" +
+	"          # Namespace is where the secret exists.\n" +
+	"          namespace: ' '\n" +
+	"    # QuayProvisioning, when set, creates the repositories on quay.io\n" +
+	"    # that images are promoted to but that do not exist yet, as\n" +
+	"    # pushing to a missing repository fails.\n" +
+	"    quay_provisioning:\n" +
+	"        # Teams are granted permissions on the created repositories.\n" +
+	"        teams:\n" +
+	"            - # Name is the name of the team.\n" +
+	"              name: ' '\n" +
+	"              # Role is one of read, write or admin.\n" +
+	"              role: ' '\n" +
+	"        # TokenSecret is the secret holding the OAuth token for the\n" +
+	"        # Quay API under the `token` key. It must exist in the\n" +
+	"        # test-credentials namespace.\n" +
+	"        token_secret:\n" +
+	"            # Name is the name of the secret.\n" +
+	"            name: ' '\n" +
+	"            # Namespace is where the secret exists.\n" +
+	"            namespace: ' '\n" +
+	"        # Visibility of the created repositories, private by default.\n" +
+	"        visibility: ' '\n" +
 	"    # RegistryOverride is an override for the registry domain to\n" +
 	"    # which we will mirror images. This is an advanced option and\n" +
 	"    # should *not* be used in common test workflows. The CI chat\n" +
 	"    # bot uses this option to facilitate image sharing.\n" +
 	"    registry_override: ' '\n" +
+	"    # SignatureVerification, when set, verifies after promotion that\n" +
+	"    # the promoted images are signed by one of the expected signers.\n" +
+	"    signature_verification:\n" +
+	"        # Identities are the accepted signers. An image passes\n" +
+	"        # verification when it is signed by any one of them.\n" +
+	"        identities:\n" +
+	"            - # Identity is the expected subject of the signing certificate\n" +
+	"              identity: ' '\n" +
+	"              # Issuer is the expected OIDC issuer of the signing certificate\n" +
+	"              issuer: ' '\n" +
+	"        # Image is the image stream tag of an image providing the\n" +
+	"        # cosign binary that verifies the signatures.\n" +
+	"        image:\n" +
+	"            # As is an optional string to use as the intermediate name for this reference.\n" +
+	"            as: ' '\n" +
+	"            name: ' '\n" +
+	"            namespace: ' '\n" +
+	"            tag: ' '\n" +
+	"        # Policy determines what happens when an image fails\n" +
+	"        # verification. Can be: enforce (default) or warn.\n" +
+	"        # Images are verified right after they are pushed to the\n" +
+	"        # promotion registry. With enforce, a failure stops the\n" +
+	"        # promotion before aliases, archive tags and additional\n" +
+	"        # registries are pushed, but the images that were already\n" +
+	"        # pushed to the promotion registry stay there.\n" +
+	"        policy: ' '\n" +
+	"    # SkipIfOnlyChanged is a regular expression matched against the\n" +
+	"    # files changed by all of the promoted commits. Promotion is\n" +
+	"    # skipped when every changed file matches, e.g. for changes that\n" +
+	"    # only touch documentation. When the range of promoted commits\n" +
+	"    # cannot be determined, the images are promoted.\n" +
+	"    skip_if_only_changed: ' '\n" +
 	"    # Tag is the ImageStreamTag tagged in for each\n" +
 	"    # build image's ImageStream.\n" +
 	"    tag: ' '\n" +
+	"    # Timeout is how long the promotion may take before it is\n" +
+	"    # aborted, so that a promotion of a huge payload fails with a\n" +
+	"    # clear reason instead of being killed by the job timeout.\n" +
+	"    timeout: 0s\n" +
+	"    # VulnerabilityScan, when set, scans the images before they\n" +
+	"    # are promoted and blocks the promotion of images with too\n" +
+	"    # many critical vulnerabilities.\n" +
+	"    vulnerability_scan:\n" +
+	"        # Allowlist are IDs of vulnerabilities, e.g. CVE-2021-3449,\n" +
+	"        # that are not counted, for example because they do not\n" +
+	"        # affect the repository.\n" +
+	"        allowlist:\n" +
+	"            - \"\"\n" +
+	"        # Image is the image stream tag of an image providing the\n" +
+	"        # trivy binary that scans the images.\n" +
+	"        image:\n" +
+	"            # As is an optional string to use as the intermediate name for this reference.\n" +
+	"            as: ' '\n" +
+	"            name: ' '\n" +
+	"            namespace: ' '\n" +
+	"            tag: ' '\n" +
 	"# RawSteps are literal Steps that should be\n" +
 	"# included in the final pipeline.\n" +
 	"raw_steps:\n" +
@@ -299,6 +469,10 @@ const ciOperatorReferenceYaml = "# The list of base images describe\n" +
 	"                      destination_dir: ' '\n" +
 	"                      # SourcePath is a file or directory in the source image to copy from.\n" +
 	"                      source_path: ' '\n" +
+	"        # Promote, when set to false, excludes the image from promotion\n" +
+	"        # as if it were listed in excluded_images. The image is still\n" +
+	"        # built and can be used in tests.\n" +
+	"        promote: false\n" +
 	"        to: ' '\n" +
 	"      release_images_tag_step:\n" +
 	"        # Name is the image stream name to use that contains all\n" +